@@ -0,0 +1,276 @@
+// Package assembler turns the AST built by the parser package into fully
+// numeric instructions ready to be loaded into a core. As the ICWS'94
+// standard mandates, every operand ends up being an offset relative to
+// the instruction it belongs to.
+package assembler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/pcolladosoto/corewarg/parser"
+)
+
+var (
+	ErrUndefinedLabel  = errors.New("undefined label")
+	ErrDuplicateLabel  = errors.New("duplicate label")
+	ErrStartOutOfRange = errors.New("start out of range")
+	ErrMissingOperand  = errors.New("missing operand")
+)
+
+// Instruction is an assembled instruction: there are no labels
+// nor expressions left, just numbers.
+type Instruction struct {
+	Opcode   parser.Opcode
+	Modifier parser.OpcodeModifier
+	AMode    parser.AddressingMode
+	AField   int
+	BMode    parser.AddressingMode
+	BField   int
+}
+
+// Warrior is an assembled program. Its name comes from the ;name comment,
+// falling back to the program's name, and its author from ;author.
+type Warrior struct {
+	Name     string
+	Author   string
+	Start    int  // offset of the first instruction to execute
+	PIN      *int // P-space identifier given by PIN, if any
+	Code     []Instruction
+	Warnings []string
+}
+
+// assembler holds the state needed to assemble a single program.
+type assembler struct {
+	prog     *parser.Program
+	labels   map[parser.Label]int // label -> address
+	errs     []error
+	warnings []string
+}
+
+// Assemble resolves every label in p and evaluates every operand. Omitted
+// operands, addressing modes and modifiers are filled in following the
+// standard's defaults. All the
+// errors found along the way are reported together. Anything after END is
+// ignored and the start offset is taken from ORG or END's operand.
+func Assemble(p *parser.Program) (*Warrior, error) {
+	a := &assembler{prog: p, labels: map[parser.Label]int{}}
+	a.collectLabels()
+
+	w := &Warrior{Name: p.Name, Author: p.Author}
+	if p.Warrior != "" {
+		w.Name = p.Warrior
+	}
+	for _, ins := range a.program() {
+		if !ins.Operation.Opcode.IsPseudo() {
+			w.Code = append(w.Code, a.assemble(len(w.Code), ins))
+		}
+	}
+	w.Start = a.start(len(w.Code))
+	w.PIN = a.pin()
+
+	if len(a.errs) > 0 {
+		return nil, errors.Join(a.errs...)
+	}
+	w.Warnings = a.warnings
+	return w, nil
+}
+
+// errorf records an error found on the given line.
+func (a *assembler) errorf(line int, format string, args ...any) {
+	a.errs = append(a.errs, fmt.Errorf("%s:%d: %w", a.prog.Name, line, fmt.Errorf(format, args...)))
+}
+
+// warnf records a warning about the given line.
+func (a *assembler) warnf(line int, format string, args ...any) {
+	a.warnings = append(a.warnings, fmt.Sprintf("%s:%d: %s", a.prog.Name, line, fmt.Sprintf(format, args...)))
+}
+
+// program returns the instructions up to the first END, included.
+func (a *assembler) program() []parser.Instruction {
+	for i, ins := range a.prog.Instructions {
+		if ins.Operation.Opcode == parser.END {
+			return a.prog.Instructions[:i+1]
+		}
+	}
+	return a.prog.Instructions
+}
+
+// collectLabels computes the address of every label. Labels on pseudo-opcodes
+// point to the instruction coming right after them.
+func (a *assembler) collectLabels() {
+	lines := map[parser.Label]int{}
+	addr := 0
+	for _, ins := range a.program() {
+		for _, label := range ins.Labels {
+			if first, ok := lines[label]; ok {
+				a.errorf(ins.Line, "%w %q (first defined on line %d)", ErrDuplicateLabel, label, first)
+				continue
+			}
+			lines[label] = ins.Line
+			a.labels[label] = addr
+		}
+		if !ins.Operation.Opcode.IsPseudo() {
+			addr++
+		}
+	}
+}
+
+// start computes the start offset of a program with size instructions. Both
+// ORG and END can provide it: should they disagree ORG takes precedence.
+func (a *assembler) start(size int) int {
+	var org, end *parser.Instruction
+	for _, ins := range a.program() {
+		switch ins.Operation.Opcode {
+		case parser.ORG:
+			if org != nil {
+				a.warnf(ins.Line, "ORG redefined (first defined on line %d)", org.Line)
+			}
+			org = &ins
+		case parser.END:
+			if len(ins.Operands) > 0 {
+				end = &ins
+			}
+		}
+	}
+
+	orgStart, endStart := a.address(org, size), a.address(end, size)
+	switch {
+	case org != nil && end != nil:
+		if orgStart != endStart {
+			a.warnf(end.Line, "END's start (%d) disagrees with ORG's (%d) on line %d: using ORG's", endStart, orgStart, org.Line)
+		}
+		return orgStart
+	case org != nil:
+		return orgStart
+	}
+	return endStart
+}
+
+// pin returns the P-space identifier of the program. Warriors
+// declaring the same one share their P-space.
+func (a *assembler) pin() *int {
+	var pin *parser.Instruction
+	for _, ins := range a.program() {
+		if ins.Operation.Opcode == parser.PIN {
+			if pin != nil {
+				a.warnf(ins.Line, "PIN redefined (first defined on line %d)", pin.Line)
+			}
+			pin = &ins
+		}
+	}
+	if pin == nil {
+		return nil
+	}
+
+	v, ok := a.value(pin)
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// address evaluates the operand of ORG or END into an offset from the start of
+// the program. No instruction means the program starts at its first one.
+func (a *assembler) address(ins *parser.Instruction, size int) int {
+	if ins == nil {
+		return 0
+	}
+
+	v, ok := a.value(ins)
+	if !ok {
+		return 0
+	}
+	if v < 0 || v >= size {
+		a.errorf(ins.Line, "%w: %d is outside the program", ErrStartOutOfRange, v)
+		return 0
+	}
+	return v
+}
+
+// value evaluates the single operand of a pseudo-opcode. Labels
+// are taken as absolute addresses within the program.
+func (a *assembler) value(ins *parser.Instruction) (int, bool) {
+	if len(ins.Operands) != 1 {
+		a.errorf(ins.Line, "%s takes a single operand", ins.Operation.Opcode)
+		return 0, false
+	}
+
+	resolve := func(l parser.Label) (int, error) {
+		target, ok := a.labels[l]
+		if !ok {
+			return 0, fmt.Errorf("%w %q", ErrUndefinedLabel, l)
+		}
+		return target, nil
+	}
+
+	v, err := ins.Operands[0].Expr.Eval(resolve)
+	if err != nil {
+		a.errorf(ins.Line, "%w", err)
+		return 0, false
+	}
+	return v, true
+}
+
+// assemble evaluates the operands of ins, which lives at address addr.
+func (a *assembler) assemble(addr int, ins parser.Instruction) Instruction {
+	out := Instruction{Opcode: ins.Operation.Opcode, Modifier: ins.Operation.Modifier}
+
+	if len(ins.Operands) == 0 {
+		a.errorf(ins.Line, "%w: %s needs at least one", ErrMissingOperand, ins.Operation.Opcode)
+		return out
+	}
+	ins = normalize(ins)
+
+	// labels are relative to the instruction referencing them
+	resolve := func(l parser.Label) (int, error) {
+		target, ok := a.labels[l]
+		if !ok {
+			return 0, fmt.Errorf("%w %q", ErrUndefinedLabel, l)
+		}
+		return target - addr, nil
+	}
+
+	fields := []*int{&out.AField, &out.BField}
+	modes := []*parser.AddressingMode{&out.AMode, &out.BMode}
+	for i, operand := range ins.Operands {
+		v, err := operand.Expr.Eval(resolve)
+		if err != nil {
+			a.errorf(ins.Line, "%w", err)
+		}
+		*fields[i], *modes[i] = v, operand.Mode
+	}
+
+	if out.Modifier == parser.OPCODE_MODIFIER_INVALID {
+		out.Modifier = defaultModifier(out.Opcode, out.AMode, out.BMode)
+	}
+
+	return out
+}
+
+func (i Instruction) String() string {
+	buff := bytes.Buffer{}
+
+	buff.WriteString(i.Opcode.String())
+	if i.Modifier != parser.OPCODE_MODIFIER_INVALID {
+		buff.WriteString(fmt.Sprintf(".%s", i.Modifier))
+	}
+
+	for j, f := range []struct {
+		mode  parser.AddressingMode
+		value int
+	}{{i.AMode, i.AField}, {i.BMode, i.BField}} {
+		if j == 0 {
+			buff.WriteString(" ")
+		} else {
+			buff.WriteString(", ")
+		}
+		if f.mode != parser.ADDRESSING_MODE_INVALID {
+			buff.WriteString(f.mode.String())
+		}
+		buff.WriteString(fmt.Sprintf("%d", f.value))
+	}
+
+	return buff.String()
+}
@@ -0,0 +1,337 @@
+package assembler
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pcolladosoto/corewarg/parser"
+)
+
+func assemble(t *testing.T, in string) (*Warrior, error) {
+	t.Helper()
+	prog, err := parser.ParseString("asmTest", in)
+	if err != nil {
+		t.Fatalf("error parsing %q: %v", in, err)
+	}
+	return Assemble(prog)
+}
+
+func TestAssembleLabels(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{
+			"target  DAT.F   #0,     #0\n" +
+				"start   ADD.AB  #4,     target\n" +
+				"        MOV.AB  #0,     @target\n" +
+				"        JMP.A   start\n",
+			[]string{"DAT.F #0, #0", "ADD.AB #4, $-1", "MOV.AB #0, @-2", "JMP.A $-2, $0"},
+		},
+		{
+			"a b  JMP c\n" +
+				"c    DAT #a-c, #b-c+2\n",
+			[]string{"JMP.B $1, $0", "DAT.F #-1, #1"},
+		},
+		{
+			"       MOV 0, 1\n" +
+				"       ORG loop\n" +
+				"loop   JMP loop+1, (tail-loop)*2\n" +
+				"tail   DAT 0\n",
+			[]string{"MOV.I $0, $1", "JMP.B $1, $2", "DAT.F #0, $0"},
+		},
+		{
+			"foo\nbar JMP foo, bar\n",
+			[]string{"JMP.B $0, $0"},
+		},
+	}
+
+	for i, test := range tests {
+		w, err := assemble(t, test.in)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+
+		if len(w.Code) != len(test.want) {
+			t.Errorf("test %d: got %d instructions, want %d", i, len(w.Code), len(test.want))
+			continue
+		}
+
+		for j, ins := range w.Code {
+			if got := ins.String(); got != test.want[j] {
+				t.Errorf("test %d, instruction %d: got %q, want %q", i, j, got, test.want[j])
+			}
+		}
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  []error
+		lines []string
+	}{
+		{"JMP nowhere\n", []error{ErrUndefinedLabel}, []string{"asmTest:1:"}},
+		{"a DAT 0\na DAT 1\n", []error{ErrDuplicateLabel}, []string{"asmTest:2:"}},
+		{"a DAT 0\n\nb JMP c\nb DAT a, d\n", []error{ErrDuplicateLabel, ErrUndefinedLabel}, []string{"asmTest:4:", "asmTest:3:"}},
+		{"DAT 1/(a-a)\na DAT 0\n", []error{parser.ErrDivisionByZero}, []string{"asmTest:1:"}},
+	}
+
+	for i, test := range tests {
+		_, err := assemble(t, test.in)
+		if err == nil {
+			t.Errorf("test %d: assembled and it shouldn't have...", i)
+			continue
+		}
+
+		for _, want := range test.want {
+			if !errors.Is(err, want) {
+				t.Errorf("test %d: got %v, want %v", i, err, want)
+			}
+		}
+		for _, line := range test.lines {
+			if !strings.Contains(err.Error(), line) {
+				t.Errorf("test %d: error %q doesn't point at %q", i, err, line)
+			}
+		}
+	}
+}
+
+func TestAssembleDwarf(t *testing.T) {
+	f, err := os.Open("../lexer/testdata/dwarf.rc")
+	if err != nil {
+		t.Fatalf("error opening dwarf: %v", err)
+	}
+	defer f.Close()
+
+	prog, err := parser.Parse("dwarf.rc", f)
+	if err != nil {
+		t.Fatalf("error parsing dwarf: %v", err)
+	}
+
+	w, err := Assemble(prog)
+	if err != nil {
+		t.Fatalf("error assembling dwarf: %v", err)
+	}
+
+	if w.Start != 1 {
+		t.Errorf("got start %d, want 1", w.Start)
+	}
+
+	want := []string{"DAT.F #0, #0", "ADD.AB #4, $-1", "MOV.AB #0, @-2", "JMP.A $-2, $0"}
+	if len(w.Code) != len(want) {
+		t.Fatalf("got %d instructions, want %d", len(w.Code), len(want))
+	}
+	for i, ins := range w.Code {
+		if got := ins.String(); got != want[i] {
+			t.Errorf("instruction %d: got %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestAssembleStart(t *testing.T) {
+	tests := []struct {
+		in       string
+		start    int
+		size     int
+		warnings int
+	}{
+		{"DAT 0\nDAT 1\n", 0, 2, 0},
+		{"ORG 1\nDAT 0\nDAT 1\n", 1, 2, 0},
+		{"DAT 0\nstart DAT 1\nEND start\n", 1, 2, 0},
+		{"ORG start\nDAT 0\nstart DAT 1\nEND start\n", 1, 2, 0},
+		{"ORG start\nDAT 0\nstart DAT 1\nEND 0\n", 1, 2, 1},
+		{"ORG 0\nORG 1\nDAT 0\nDAT 1\n", 1, 2, 1},
+		{"DAT 0\nhere ORG here\nDAT 1\n", 1, 2, 0},
+		{"ORG start-1\nDAT 0\nstart DAT 1\nEND\n", 0, 2, 0},
+	}
+
+	for i, test := range tests {
+		w, err := assemble(t, test.in)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if w.Start != test.start {
+			t.Errorf("test %d: got start %d, want %d", i, w.Start, test.start)
+		}
+		if len(w.Code) != test.size {
+			t.Errorf("test %d: got %d instructions, want %d", i, len(w.Code), test.size)
+		}
+		if len(w.Warnings) != test.warnings {
+			t.Errorf("test %d: got warnings %q, want %d of them", i, w.Warnings, test.warnings)
+		}
+	}
+}
+
+func TestAssembleName(t *testing.T) {
+	tests := []struct {
+		in           string
+		name, author string
+	}{
+		{"DAT 0\n", "asmTest", ""},
+		{";name Imp\nMOV 0, 1\n", "Imp", ""},
+		{";author A. K. Dewdney\n;name Imp\nMOV 0, 1\n", "Imp", "A. K. Dewdney"},
+	}
+
+	for i, test := range tests {
+		w, err := assemble(t, test.in)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if w.Name != test.name || w.Author != test.author {
+			t.Errorf("test %d: got %q by %q, want %q by %q", i, w.Name, w.Author, test.name, test.author)
+		}
+	}
+}
+
+func TestAssemblePIN(t *testing.T) {
+	tests := []struct {
+		in       string
+		pin      *int
+		size     int
+		warnings int
+	}{
+		{"DAT 0\n", nil, 1, 0},
+		{"PIN 42\nDAT 0\n", ptr(42), 1, 0},
+		{"PIN 6*7\nDAT 0\nPIN -1\n", ptr(-1), 1, 1},
+		{"DAT 0\nhere PIN here\nDAT 1\n", ptr(1), 2, 0},
+		{"DAT 0\nEND\nPIN 3\n", nil, 1, 0},
+	}
+
+	for i, test := range tests {
+		w, err := assemble(t, test.in)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if (w.PIN == nil) != (test.pin == nil) || w.PIN != nil && *w.PIN != *test.pin {
+			t.Errorf("test %d: got PIN %v, want %v", i, w.PIN, test.pin)
+		}
+		if len(w.Code) != test.size {
+			t.Errorf("test %d: got %d instructions, want %d", i, len(w.Code), test.size)
+		}
+		if len(w.Warnings) != test.warnings {
+			t.Errorf("test %d: got warnings %q, want %d of them", i, w.Warnings, test.warnings)
+		}
+	}
+
+	if _, err := assemble(t, "PIN nowhere\nDAT 0\n"); !errors.Is(err, ErrUndefinedLabel) {
+		t.Errorf("got %v, want %v", err, ErrUndefinedLabel)
+	}
+}
+
+func ptr(v int) *int {
+	return &v
+}
+
+func TestAssembleStartErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want error
+	}{
+		{"ORG 2\nDAT 0\nDAT 1\n", ErrStartOutOfRange},
+		{"DAT 0\nEND -1\n", ErrStartOutOfRange},
+		{"ORG nowhere\nDAT 0\n", ErrUndefinedLabel},
+		{"DAT 0\nEND 1/0\n", parser.ErrDivisionByZero},
+		{"DAT 0\n  END start\nstart DAT 1\n", ErrUndefinedLabel},
+	}
+
+	for i, test := range tests {
+		_, err := assemble(t, test.in)
+		if !errors.Is(err, test.want) {
+			t.Errorf("test %d: got %v, want %v", i, err, test.want)
+		}
+	}
+}
+
+func TestDefaultModifiers(t *testing.T) {
+	// Expected modifier depending on which operands are immediate:
+	// both of them, only A, only B and none of them.
+	rules := map[parser.Opcode][4]parser.OpcodeModifier{
+		parser.DAT: {parser.F, parser.F, parser.F, parser.F},
+		parser.MOV: {parser.AB, parser.AB, parser.B, parser.I},
+		parser.CMP: {parser.AB, parser.AB, parser.B, parser.I},
+		parser.ADD: {parser.AB, parser.AB, parser.B, parser.F},
+		parser.SUB: {parser.AB, parser.AB, parser.B, parser.F},
+		parser.MUL: {parser.AB, parser.AB, parser.B, parser.F},
+		parser.DIV: {parser.AB, parser.AB, parser.B, parser.F},
+		parser.MOD: {parser.AB, parser.AB, parser.B, parser.F},
+		parser.SLT: {parser.AB, parser.AB, parser.B, parser.B},
+		parser.JMP: {parser.B, parser.B, parser.B, parser.B},
+		parser.JMZ: {parser.B, parser.B, parser.B, parser.B},
+		parser.JMN: {parser.B, parser.B, parser.B, parser.B},
+		parser.DJN: {parser.B, parser.B, parser.B, parser.B},
+		parser.SPL: {parser.B, parser.B, parser.B, parser.B},
+		parser.SEQ: {parser.AB, parser.AB, parser.B, parser.I},
+		parser.SNE: {parser.AB, parser.AB, parser.B, parser.I},
+		parser.NOP: {parser.F, parser.F, parser.F, parser.F},
+		parser.LDP: {parser.AB, parser.AB, parser.B, parser.B},
+		parser.STP: {parser.AB, parser.AB, parser.B, parser.B},
+	}
+
+	modes := []parser.AddressingMode{
+		parser.ADDRESSING_MODE_INVALID, parser.Hash, parser.Dollar, parser.At, parser.Lt, parser.Gt,
+		parser.Asterisk, parser.LBrace, parser.RBrace,
+	}
+
+	for op, want := range rules {
+		for _, aMode := range modes {
+			for _, bMode := range modes {
+				var w parser.OpcodeModifier
+				switch {
+				case aMode == parser.Hash && bMode == parser.Hash:
+					w = want[0]
+				case aMode == parser.Hash:
+					w = want[1]
+				case bMode == parser.Hash:
+					w = want[2]
+				default:
+					w = want[3]
+				}
+
+				if got := defaultModifier(op, aMode, bMode); got != w {
+					t.Errorf("%s %s, %s: got .%s, want .%s", op, aMode, bMode, got, w)
+				}
+			}
+		}
+	}
+}
+
+func TestAssembleDefaultModifiers(t *testing.T) {
+	in := "MOV #1, 2\nMOV 1, #2\nMOV 1, 2\nADD 1, 2\nSLT 1, #2\nDAT 0\nSPL.A 0\n"
+	want := []string{"MOV.AB #1, $2", "MOV.B $1, #2", "MOV.I $1, $2", "ADD.F $1, $2", "SLT.B $1, #2", "DAT.F #0, $0", "SPL.A $0, $0"}
+
+	w, err := assemble(t, in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, ins := range w.Code {
+		if got := ins.String(); got != want[i] {
+			t.Errorf("instruction %d: got %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestAssembleNormalize(t *testing.T) {
+	in := "DAT 5\nDAT #5\nDAT @1, <2\nJMP 3\nSPL #1\nMOV >1, 2\n"
+	want := []string{"DAT.F #0, $5", "DAT.F #0, #5", "DAT.F @1, <2", "JMP.B $3, $0", "SPL.B #1, $0", "MOV.I >1, $2"}
+
+	w, err := assemble(t, in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, ins := range w.Code {
+		if got := ins.String(); got != want[i] {
+			t.Errorf("instruction %d: got %q, want %q", i, got, want[i])
+		}
+	}
+
+	if _, err := assemble(t, "JMP\n"); !errors.Is(err, ErrMissingOperand) {
+		t.Errorf("got %v, want %v", err, ErrMissingOperand)
+	}
+}
@@ -0,0 +1,41 @@
+package assembler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteLoadFile writes w in the ICWS'94 load file format: every instruction
+// with its modifier, modes and numeric fields spelled out, preceded by an
+// ORG giving the start offset and followed by an END. The name, author and
+// PIN of w are kept too, so parsing and assembling a load file gives back
+// the very same warrior.
+func (w *Warrior) WriteLoadFile(out io.Writer) error {
+	var b strings.Builder
+	if w.Name != "" {
+		fmt.Fprintf(&b, ";name %s\n", w.Name)
+	}
+	if w.Author != "" {
+		fmt.Fprintf(&b, ";author %s\n", w.Author)
+	}
+
+	fmt.Fprintf(&b, "ORG %d\n", w.Start)
+	if w.PIN != nil {
+		fmt.Fprintf(&b, "PIN %d\n", *w.PIN)
+	}
+	for _, ins := range w.Code {
+		fmt.Fprintf(&b, "%s\n", ins)
+	}
+	b.WriteString("END\n")
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// LoadFile returns w in the load file format.
+func (w *Warrior) LoadFile() string {
+	var b strings.Builder
+	w.WriteLoadFile(&b)
+	return b.String()
+}
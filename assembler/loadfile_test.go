@@ -0,0 +1,60 @@
+package assembler
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pcolladosoto/corewarg/parser"
+)
+
+func TestLoadFile(t *testing.T) {
+	w, err := assemble(t, ";name Imp\n;author A. K. Dewdney\nstart MOV 0, 1\nEND start\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := ";name Imp\n;author A. K. Dewdney\nORG 0\nMOV.I $0, $1\nEND\n"
+	if got := w.LoadFile(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestLoadFileRoundTrip assembles warriors, parses their load files and
+// assembles them again, which must give back exactly the same warriors.
+func TestLoadFileRoundTrip(t *testing.T) {
+	dwarf, err := os.ReadFile("../lexer/testdata/dwarf.rc")
+	if err != nil {
+		t.Fatalf("error reading dwarf.rc: %v", err)
+	}
+
+	tests := []string{
+		string(dwarf),
+		"DAT 0\n",
+		"PIN 7\n  SPL 2\nptr JMP }ptr, {ptr\n   ADD.X *ptr, >-20\nSEQ #1, <3\nLDP.AB #0, ptr\nEND 1\n",
+		"step EQU 2*3\nMOV -step, 79\nMOD.BA #step, @-1\n",
+	}
+
+	for i, test := range tests {
+		w, err := assemble(t, test)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+
+		prog, err := parser.ParseString("loadFile", w.LoadFile())
+		if err != nil {
+			t.Errorf("test %d: error parsing load file %q: %v", i, w.LoadFile(), err)
+			continue
+		}
+		again, err := Assemble(prog)
+		if err != nil {
+			t.Errorf("test %d: error assembling load file %q: %v", i, w.LoadFile(), err)
+			continue
+		}
+
+		if !reflect.DeepEqual(again, w) {
+			t.Errorf("test %d: got %+v, want %+v", i, again, w)
+		}
+	}
+}
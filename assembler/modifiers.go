@@ -0,0 +1,46 @@
+package assembler
+
+import "github.com/pcolladosoto/corewarg/parser"
+
+// defaultModifier returns the modifier the ICWS'94 standard assigns to an
+// instruction whose modifier was omitted. It depends on the opcode and on
+// whether any of the operands is immediate. Note an omitted addressing mode
+// is direct, so it doesn't count as immediate.
+func defaultModifier(op parser.Opcode, aMode, bMode parser.AddressingMode) parser.OpcodeModifier {
+	aHash, bHash := aMode == parser.Hash, bMode == parser.Hash
+
+	switch op {
+	case parser.DAT, parser.NOP:
+		return parser.F
+
+	case parser.MOV, parser.CMP, parser.SEQ, parser.SNE:
+		switch {
+		case aHash:
+			return parser.AB
+		case bHash:
+			return parser.B
+		}
+		return parser.I
+
+	case parser.ADD, parser.SUB, parser.MUL, parser.DIV, parser.MOD:
+		switch {
+		case aHash:
+			return parser.AB
+		case bHash:
+			return parser.B
+		}
+		return parser.F
+
+	case parser.SLT, parser.LDP, parser.STP:
+		if aHash {
+			return parser.AB
+		}
+		return parser.B
+
+	case parser.JMP, parser.JMZ, parser.JMN, parser.DJN, parser.SPL:
+		return parser.B
+	}
+
+	// pseudo-opcodes never make it into the core
+	return parser.OPCODE_MODIFIER_INVALID
+}
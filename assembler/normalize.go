@@ -0,0 +1,32 @@
+package assembler
+
+import (
+	"slices"
+
+	"github.com/pcolladosoto/corewarg/parser"
+)
+
+// normalize fills in whatever the standard allows omitting so that ins ends
+// up with two operands, each with a concrete addressing mode. An omitted mode
+// is direct ($). A lone DAT operand is its B-operand and the A-operand becomes
+// #0. Any other opcode with a single operand gets $0 as its B-operand.
+func normalize(ins parser.Instruction) parser.Instruction {
+	operands := slices.Clone(ins.Operands)
+
+	if len(operands) == 1 {
+		if ins.Operation.Opcode == parser.DAT {
+			operands = []parser.Operand{{Mode: parser.Hash}, operands[0]}
+		} else {
+			operands = append(operands, parser.Operand{Mode: parser.Dollar})
+		}
+	}
+
+	for i := range operands {
+		if operands[i].Mode == parser.ADDRESSING_MODE_INVALID {
+			operands[i].Mode = parser.Dollar
+		}
+	}
+
+	ins.Operands = operands
+	return ins
+}
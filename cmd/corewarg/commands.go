@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pcolladosoto/corewarg/assembler"
+	"github.com/pcolladosoto/corewarg/format"
+	"github.com/pcolladosoto/corewarg/mars"
+	"github.com/pcolladosoto/corewarg/match"
+)
+
+func parseFlags(fs *flag.FlagSet) func(e *env, files []string) error {
+	indent := fs.Bool("indent", true, "indent the JSON output")
+
+	return func(e *env, files []string) error {
+		for _, path := range files {
+			p, err := parse(path)
+			if err != nil {
+				return err
+			}
+
+			var out []byte
+			if *indent {
+				out, err = json.MarshalIndent(p, "", "    ")
+			} else {
+				out, err = json.Marshal(p)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			fmt.Fprintf(e.stdout, "%s\n", out)
+		}
+		return nil
+	}
+}
+
+func assembleFlags(fs *flag.FlagSet) func(e *env, files []string) error {
+	return func(e *env, files []string) error {
+		for i, path := range files {
+			w, err := load(path)
+			if err != nil {
+				return err
+			}
+			warn(e, w)
+
+			if i > 0 {
+				fmt.Fprintln(e.stdout)
+			}
+			if err := w.WriteLoadFile(e.stdout); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// runFlags registers the flags of the run command. On top of the match
+// flags, these are the ones pMARS takes to tweak its output. Unless told
+// otherwise results are printed just like pMARS does, so that corewarg
+// can stand in for it in existing scripts.
+func runFlags(fs *flag.FlagSet) func(e *env, files []string) error {
+	var cfg match.Config
+	var seed int64
+	matchFlags(fs, &cfg, &seed)
+
+	var fixed int
+	var brief, order, koth bool
+	fs.IntVar(&fixed, "F", 0, "fixed position of the second warrior: 0 places it at random")
+	fs.BoolVar(&brief, "b", false, "brief mode: don't list the warriors")
+	fs.BoolVar(&order, "o", false, "order results by score")
+	fs.BoolVar(&koth, "k", false, "print the wins, ties and losses of each warrior as KotH scripts expect")
+
+	return func(e *env, files []string) error {
+		ws := []*assembler.Warrior{}
+		for _, path := range files {
+			w, err := load(path)
+			if err != nil {
+				return err
+			}
+			warn(e, w)
+			ws = append(ws, w)
+		}
+
+		if fixed != 0 {
+			if len(ws) != 2 {
+				return fmt.Errorf("-F needs exactly 2 warriors, got %d", len(ws))
+			}
+			cfg.Placement = mars.FixedPlacement{0, fixed}
+		}
+		cfg.Seed = pickSeed(seed)
+		r, err := match.Run(cfg, ws)
+		if err != nil {
+			return err
+		}
+
+		if !brief {
+			for _, w := range ws {
+				fmt.Fprintf(e.stdout, "Program %q (length %d) by %q\n\n", w.Name, len(w.Code), author(w))
+				if err := w.WriteLoadFile(e.stdout); err != nil {
+					return err
+				}
+				fmt.Fprintln(e.stdout)
+			}
+		}
+
+		scores := make([]int, len(ws))
+		for i := range scores {
+			scores[i] = i
+		}
+		if order {
+			slices.SortStableFunc(scores, func(a, b int) int {
+				return r.Scores[b].Points() - r.Scores[a].Points()
+			})
+		}
+
+		if koth {
+			for _, i := range scores {
+				s := r.Scores[i]
+				fmt.Fprintf(e.stdout, "%d %d %d\n", s.Wins, s.Ties, s.Losses)
+			}
+			return nil
+		}
+
+		for _, i := range scores {
+			fmt.Fprintf(e.stdout, "%s by %s scores %d\n", ws[i].Name, author(ws[i]), r.Scores[i].Points())
+		}
+
+		// The results line has the wins of every warrior in the order they
+		// were given followed by the rounds nobody won.
+		results, ties := []string{}, r.Rounds
+		for _, s := range r.Scores {
+			results = append(results, strconv.Itoa(s.Wins))
+			ties -= s.Wins
+		}
+		fmt.Fprintf(e.stdout, "Results: %s %d\n", strings.Join(results, " "), ties)
+		return nil
+	}
+}
+
+func checkFlags(fs *flag.FlagSet) func(e *env, files []string) error {
+	var cfg mars.Config
+	marsFlags(fs, &cfg)
+
+	return func(e *env, files []string) error {
+		errs := []error{}
+		for _, path := range files {
+			w, err := load(path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			warn(e, w)
+
+			// Loading it is the only way to know whether it fits.
+			m, err := mars.New(cfg)
+			if err != nil {
+				return err
+			}
+			if _, err := m.Load(w, 0); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// fmtFlags registers the flags of the fmt command which, just like gofmt,
+// prints the formatted warriors unless told to write them back or to
+// show what would change.
+func fmtFlags(fs *flag.FlagSet) func(e *env, files []string) error {
+	var write, show bool
+	fs.BoolVar(&write, "w", false, "write the result back to the files instead of printing it")
+	fs.BoolVar(&show, "d", false, "print diffs instead of the formatted warriors")
+
+	return func(e *env, files []string) error {
+		for _, path := range files {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			out, err := format.Source(path, src)
+			if err != nil {
+				return err
+			}
+
+			if show {
+				diff(e.stdout, path, src, out)
+			}
+			if write && !bytes.Equal(src, out) {
+				if err := os.WriteFile(path, out, 0o644); err != nil {
+					return err
+				}
+			}
+			if !show && !write {
+				e.stdout.Write(out)
+			}
+		}
+		return nil
+	}
+}
+
+// warn prints the warnings found while assembling w.
+func warn(e *env, w *assembler.Warrior) {
+	for _, msg := range w.Warnings {
+		fmt.Fprintf(e.stderr, "warning: %s\n", msg)
+	}
+}
+
+// author returns the author of w just like pMARS names unknown ones.
+func author(w *assembler.Warrior) string {
+	if w.Author == "" {
+		return "Anonymous"
+	}
+	return w.Author
+}
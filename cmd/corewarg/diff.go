@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pcolladosoto/corewarg/format"
+)
+
+// context is the number of unchanged lines shown around every change.
+const context = 3
+
+// edit is a line in a diff: kept (' '), deleted ('-') or inserted ('+').
+type edit struct {
+	op   byte
+	text string
+}
+
+// diff writes the changes turning a into b in the unified format, as diff -u
+// would, labelling the files as name.orig and name. Nothing is written when
+// they're the same.
+func diff(w io.Writer, name string, a, b []byte) {
+	edits := lcs(lines(a), lines(b))
+
+	// Group the changes into hunks, merging those whose contexts overlap.
+	var hunks [][2]int
+	for i, e := range edits {
+		if e.op == ' ' {
+			continue
+		}
+		start, end := max(i-context, 0), min(i+context+1, len(edits))
+		if n := len(hunks); n > 0 && start <= hunks[n-1][1] {
+			hunks[n-1][1] = end
+			continue
+		}
+		hunks = append(hunks, [2]int{start, end})
+	}
+	if len(hunks) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "--- %s.orig\n+++ %s\n", name, name)
+	for _, h := range hunks {
+		// line numbers of the hunk's start in each file, beginning at 1
+		aLine, bLine := 1, 1
+		for _, e := range edits[:h[0]] {
+			if e.op != '+' {
+				aLine++
+			}
+			if e.op != '-' {
+				bLine++
+			}
+		}
+
+		aLen, bLen := 0, 0
+		for _, e := range edits[h[0]:h[1]] {
+			if e.op != '+' {
+				aLen++
+			}
+			if e.op != '-' {
+				bLen++
+			}
+		}
+
+		// empty ranges refer to the line right before them
+		if aLen == 0 {
+			aLine--
+		}
+		if bLen == 0 {
+			bLine--
+		}
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", aLine, aLen, bLine, bLen)
+		for _, e := range edits[h[0]:h[1]] {
+			fmt.Fprintf(w, "%c%s\n", e.op, strings.TrimRight(e.text, "\r\n"))
+		}
+	}
+}
+
+// lines splits text into lines with the same EOLs the lexer accepts. They
+// keep them so that changing just the EOL shows up as a change too.
+func lines(text []byte) []string {
+	ls := format.SplitLines(string(text))
+	if ls[len(ls)-1] == "" {
+		ls = ls[:len(ls)-1]
+	}
+	return ls
+}
+
+// lcs computes the edits turning a into b through their longest common
+// subsequence. Warriors are short, so the quadratic table is no problem.
+func lcs(a, b []string) []edit {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	edits := []edit{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i, j = i+1, j+1
+		case table[i+1][j] >= table[i][j+1]:
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		edits = append(edits, edit{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, edit{'+', b[j]})
+	}
+	return edits
+}
@@ -0,0 +1,180 @@
+// Command corewarg parses, assembles and runs Redcode warriors.
+//
+// Usage:
+//
+//	corewarg <command> [flags] <file>...
+//
+// The commands are:
+//
+//	parse     print the AST of each warrior as JSON
+//	assemble  print the load file of each warrior
+//	run       fight a match between the warriors
+//	check     report any errors and warnings in the warriors
+//	fmt       rewrite the warriors in canonical form
+//
+// Run 'corewarg <command> -h' to list the flags a command takes. Those
+// configuring the simulator are the same for every command using it and
+// they can be given as the single letters pMARS uses too. On top of those,
+// run takes pMARS's -F, -b, -o and -k and prints its results the same way:
+//
+//	corewarg run -r 1000 -s 8000 -c 80000 -p 8000 -l 100 -d 100 -b imp.rc dwarf.rc
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pcolladosoto/corewarg/assembler"
+	"github.com/pcolladosoto/corewarg/mars"
+	"github.com/pcolladosoto/corewarg/match"
+	"github.com/pcolladosoto/corewarg/parser"
+)
+
+// command is a corewarg subcommand. Calling flags registers its flags and
+// returns the function running it on the files left after parsing them.
+type command struct {
+	name  string
+	short string
+	flags func(fs *flag.FlagSet) func(e *env, files []string) error
+}
+
+// env is what commands write to.
+type env struct {
+	stdout, stderr io.Writer
+}
+
+var commands = []command{
+	{"parse", "print the AST of each warrior as JSON", parseFlags},
+	{"assemble", "print the load file of each warrior", assembleFlags},
+	{"run", "fight a match between the warriors", runFlags},
+	{"check", "report any errors and warnings in the warriors", checkFlags},
+	{"fmt", "rewrite the warriors in canonical form", fmtFlags},
+}
+
+func main() {
+	os.Exit(corewarg(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// corewarg runs the command in args and returns the exit status: 0 on
+// success, 1 if the command failed and 2 if it was misused.
+func corewarg(args []string, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		usage(stderr)
+		return 2
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		fs := flag.NewFlagSet("corewarg "+cmd.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			fmt.Fprintf(stderr, "usage: corewarg %s [flags] <file>...\n\n%s.\n\nFlags:\n", cmd.name, cmd.short)
+			fs.PrintDefaults()
+		}
+		run := cmd.flags(fs)
+		if err := fs.Parse(args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			return 2
+		}
+		if fs.NArg() == 0 {
+			fmt.Fprintf(stderr, "corewarg %s: no warriors given\n", cmd.name)
+			fs.Usage()
+			return 2
+		}
+
+		if err := run(&env{stdout: stdout, stderr: stderr}, fs.Args()); err != nil {
+			fmt.Fprintf(stderr, "corewarg %s: %v\n", cmd.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "corewarg: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: corewarg <command> [flags] <file>...\n\nThe commands are:\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "\t%-9s %s\n", cmd.name, cmd.short)
+	}
+	fmt.Fprintf(w, "\nRun 'corewarg <command> -h' for the flags of a command.\n")
+}
+
+// marsFlags registers the flags configuring the simulator, storing their
+// values in cfg. Every flag can also be given with the single letter pMARS
+// uses for it.
+func marsFlags(fs *flag.FlagSet, cfg *mars.Config) {
+	*cfg = mars.DefaultConfig()
+	intFlags(fs, []intFlag{
+		{&cfg.CoreSize, "size", "s", "number of instructions in the core"},
+		{&cfg.MaxCycles, "cycles", "c", "cycles before a round is declared a tie"},
+		{&cfg.MaxProcesses, "processes", "p", "maximum number of processes per warrior"},
+		{&cfg.MaxLength, "length", "l", "maximum number of instructions in a warrior"},
+		{&cfg.MinDistance, "distance", "d", "minimum distance between warriors: 0 means the maximum length"},
+	})
+}
+
+// matchFlags registers the flags configuring a match, which include those
+// of the simulator, storing their values in cfg and seed.
+func matchFlags(fs *flag.FlagSet, cfg *match.Config, seed *int64) {
+	*cfg = match.DefaultConfig()
+	marsFlags(fs, &cfg.Config)
+	intFlags(fs, []intFlag{
+		{&cfg.Rounds, "rounds", "r", "number of rounds to fight"},
+	})
+	fs.Int64Var(seed, "seed", -1, "seed for the random placement of warriors: negative picks one")
+}
+
+// intFlag is an integer flag going by both a name and a single letter.
+type intFlag struct {
+	v            *int
+	name, letter string
+	usage        string
+}
+
+// intFlags registers every flag in flags, defaulting to the value they
+// point to.
+func intFlags(fs *flag.FlagSet, flags []intFlag) {
+	for _, f := range flags {
+		fs.IntVar(f.v, f.name, *f.v, f.usage)
+		fs.IntVar(f.v, f.letter, *f.v, "same as -"+f.name)
+	}
+}
+
+// pickSeed turns the value of the -seed flag into an actual seed.
+func pickSeed(seed int64) uint64 {
+	if seed < 0 {
+		return uint64(time.Now().UnixNano())
+	}
+	return uint64(seed)
+}
+
+// parse reads and parses the warrior in the file at path.
+func parse(path string) (*parser.Program, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parser.Parse(path, f)
+}
+
+// load parses and assembles the warrior in the file at path.
+func load(path string) (*assembler.Warrior, error) {
+	p, err := parse(path)
+	if err != nil {
+		return nil, err
+	}
+	return assembler.Assemble(p)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const dwarf = "../../lexer/testdata/dwarf.rc"
+
+// warrior writes src to a file in a temporary directory and returns its path.
+func warrior(t *testing.T, name, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("error writing %q: %v", path, err)
+	}
+	return path
+}
+
+func TestCorewarg(t *testing.T) {
+	imp := warrior(t, "imp.rc", "MOV 0, 1\n")
+	broken := warrior(t, "broken.rc", "MOV nowhere, 1\n")
+	long := warrior(t, "long.rc", strings.Repeat("DAT 0\n", 11))
+	warned := warrior(t, "warned.rc", "ORG 0\nORG 0\nDAT 0\n")
+
+	tests := []struct {
+		args   []string
+		status int
+		stdout string // expected to be contained in the output
+		stderr string
+	}{
+		{nil, 2, "", "usage: corewarg <command>"},
+		{[]string{"fight"}, 2, "", `unknown command "fight"`},
+		{[]string{"run"}, 2, "", "no warriors given"},
+		{[]string{"run", "-bogus", imp}, 2, "", "flag provided but not defined"},
+		{[]string{"run", "-h"}, 0, "", "-rounds"},
+		{[]string{"parse", imp}, 0, `"instructions"`, ""},
+		{[]string{"assemble", dwarf}, 0, ";name Dwarf\n;author A. K. Dewdney\nORG 1\nDAT.F #0, #0\nADD.AB #4, $-1\nMOV.AB #0, @-2\nJMP.A $-2, $0\nEND\n", ""},
+		{[]string{"assemble", broken}, 1, "", "undefined label"},
+		{[]string{"check", imp, dwarf}, 0, "", ""},
+		{[]string{"check", broken, imp}, 1, "", "undefined label"},
+		{[]string{"check", "-length", "10", long}, 1, "", "warrior too long"},
+		{[]string{"check", warned}, 0, "", "warning: " + warned + ":2: ORG redefined"},
+		{[]string{"check", "-rounds", "3", imp}, 2, "", "flag provided but not defined: -rounds"},
+		{[]string{"check", "-seed", "1", imp}, 2, "", "flag provided but not defined: -seed"},
+		{[]string{"run", "-size", "80", "-length", "10", "-cycles", "100", "-rounds", "3", "-seed", "1", imp, imp}, 0,
+			imp + " by Anonymous scores 3\nResults: 0 0 3\n", ""},
+		{[]string{"run", "-rounds", "0", imp}, 1, "", "rounds must be positive"},
+	}
+
+	for i, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if got := corewarg(test.args, &stdout, &stderr); got != test.status {
+			t.Errorf("test %d (%q): got status %d, want %d (stderr: %q)", i, test.args, got, test.status, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), test.stdout) {
+			t.Errorf("test %d (%q): stdout %q doesn't contain %q", i, test.args, stdout.String(), test.stdout)
+		}
+		if !strings.Contains(stderr.String(), test.stderr) {
+			t.Errorf("test %d (%q): stderr %q doesn't contain %q", i, test.args, stderr.String(), test.stderr)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if status := corewarg([]string{"parse", "-indent=false", dwarf}, &stdout, &stderr); status != 0 {
+		t.Fatalf("got status %d: %s", status, stderr.String())
+	}
+
+	var prog struct {
+		Name         string
+		Instructions []json.RawMessage
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &prog); err != nil {
+		t.Fatalf("error unmarshalling %q: %v", stdout.String(), err)
+	}
+	if prog.Name != dwarf || len(prog.Instructions) != 6 {
+		t.Errorf("got %q with %d instructions, want %q with 6", prog.Name, len(prog.Instructions), dwarf)
+	}
+}
+
+func TestRunPMARS(t *testing.T) {
+	imp := warrior(t, "imp.rc", ";name Imp\n;author A. K. Dewdney\nMOV 0, 1\n")
+	suicide := warrior(t, "suicide.rc", ";name Suicide\nDAT 0\n")
+	sitter := warrior(t, "sitter.rc", "JMP 0\n")
+	small := []string{"run", "-s", "80", "-l", "10", "-c", "100", "-r", "3", "-seed", "1"}
+
+	tests := []struct {
+		args   []string
+		status int
+		stdout string // expected to end the output
+		stderr string
+	}{
+		{[]string{"-b", suicide, imp}, 0, "Suicide by Anonymous scores 0\nImp by A. K. Dewdney scores 9\nResults: 0 3 0\n", ""},
+		{[]string{"-b", "-o", suicide, imp}, 0, "Imp by A. K. Dewdney scores 9\nSuicide by Anonymous scores 0\nResults: 0 3 0\n", ""},
+		{[]string{"-b", imp, sitter}, 0, "Imp by A. K. Dewdney scores 3\n" + sitter + " by Anonymous scores 3\nResults: 0 0 3\n", ""},
+		{[]string{"-b", imp, sitter, suicide}, 0, "Results: 0 0 0 3\n", ""},
+		{[]string{"-b", "-k", suicide, imp}, 0, "0 0 3\n3 0 0\n", ""},
+		{[]string{"-b", "-k", "-o", suicide, imp}, 0, "3 0 0\n0 0 3\n", ""},
+		{[]string{imp, suicide}, 0, "Program \"Imp\" (length 1) by \"A. K. Dewdney\"\n\n;name Imp\n;author A. K. Dewdney\nORG 0\nMOV.I $0, $1\nEND\n\n" +
+			"Program \"Suicide\" (length 1) by \"Anonymous\"\n\n;name Suicide\nORG 0\nDAT.F #0, $0\nEND\n\n" +
+			"Imp by A. K. Dewdney scores 9\nSuicide by Anonymous scores 0\nResults: 3 0 0\n", ""},
+		{[]string{"-b", "-F", "40", imp, sitter}, 0, "Results: 0 0 3\n", ""},
+		{[]string{"-b", "-F", "5", imp, sitter}, 1, "", "warriors too close"},
+		{[]string{"-b", "-d", "30", "-F", "20", imp, sitter}, 1, "", "warriors too close"},
+		{[]string{"-b", "-F", "40", imp, sitter, suicide}, 1, "", "-F needs exactly 2 warriors"},
+		{[]string{"-b", "-p", "0", imp}, 1, "", "max processes must be positive"},
+	}
+
+	for i, test := range tests {
+		var stdout, stderr bytes.Buffer
+		args := append(slices.Clone(small), test.args...)
+		if got := corewarg(args, &stdout, &stderr); got != test.status {
+			t.Errorf("test %d (%q): got status %d, want %d (stderr: %q)", i, test.args, got, test.status, stderr.String())
+		}
+		if !strings.HasSuffix(stdout.String(), test.stdout) {
+			t.Errorf("test %d (%q): got stdout %q, want it to end with %q", i, test.args, stdout.String(), test.stdout)
+		}
+		if !strings.Contains(stderr.String(), test.stderr) {
+			t.Errorf("test %d (%q): stderr %q doesn't contain %q", i, test.args, stderr.String(), test.stderr)
+		}
+	}
+}
+
+func TestFmt(t *testing.T) {
+	src := "imp mov 0,1\nDAT #0\n"
+	formatted := "imp MOV 0, 1\n    DAT #0\n"
+	messy := warrior(t, "messy.rc", src)
+	tidy := warrior(t, "tidy.rc", formatted)
+	broken := warrior(t, "broken.rc", "MOV 0,\n")
+
+	tests := []struct {
+		args   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{[]string{"fmt", messy}, 0, formatted, ""},
+		{[]string{"fmt", "-d", messy}, 0,
+			"--- " + messy + ".orig\n+++ " + messy + "\n@@ -1,2 +1,2 @@\n-imp mov 0,1\n-DAT #0\n+imp MOV 0, 1\n+    DAT #0\n", ""},
+		{[]string{"fmt", "-d", tidy}, 0, "", ""},
+		{[]string{"fmt", broken}, 1, "", "syntax error"},
+	}
+
+	for i, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if got := corewarg(test.args, &stdout, &stderr); got != test.status {
+			t.Errorf("test %d (%q): got status %d, want %d (stderr: %q)", i, test.args, got, test.status, stderr.String())
+		}
+		if stdout.String() != test.stdout {
+			t.Errorf("test %d (%q): got stdout %q, want %q", i, test.args, stdout.String(), test.stdout)
+		}
+		if !strings.Contains(stderr.String(), test.stderr) {
+			t.Errorf("test %d (%q): stderr %q doesn't contain %q", i, test.args, stderr.String(), test.stderr)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if status := corewarg([]string{"fmt", "-w", messy}, &stdout, &stderr); status != 0 || stdout.Len() > 0 {
+		t.Fatalf("got status %d and stdout %q: %s", status, stdout.String(), stderr.String())
+	}
+	if got, err := os.ReadFile(messy); err != nil || string(got) != formatted {
+		t.Errorf("got %q (%v), want %q", got, err, formatted)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	want := "--- x.orig\n+++ x\n" +
+		"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n" +
+		"@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+13\n"
+
+	var out bytes.Buffer
+	diff(&out, "x", []byte(a), []byte(b))
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+
+	// any EOL splits lines, and changing just the EOL is a change too
+	a, b = "1\r2\r\n3\n\r4\n", "1\n2\nthree\n4\n"
+	want = "--- x.orig\n+++ x\n" +
+		"@@ -1,4 +1,4 @@\n-1\n-2\n-3\n+1\n+2\n+three\n 4\n"
+
+	out.Reset()
+	diff(&out, "x", []byte(a), []byte(b))
+	if out.String() != want {
+		t.Errorf("got\n%q\nwant\n%q", out.String(), want)
+	}
+}
@@ -0,0 +1,143 @@
+// Package diag describes the problems found in a warrior's source. Each
+// of them is a Diagnostic pointing at where it lies, and tools gather them
+// in a List so that all of them can be reported at once instead of
+// stopping at the first one.
+package diag
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pcolladosoto/corewarg/lexer"
+)
+
+// Severity tells how bad a diagnostic is.
+type Severity int
+
+const (
+	Error   Severity = iota // the warrior can't be used
+	Warning                 // the warrior works, but probably not as intended
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Code identifies the kind of a diagnostic so that tools can tell them
+// apart without looking at messages.
+type Code string
+
+const (
+	BadToken     Code = "bad-token"     // the lexer couldn't make sense of the input
+	Syntax       Code = "syntax"        // the input doesn't follow the grammar
+	EQUCycle     Code = "equ-cycle"     // EQUs defined in terms of each other
+	EQURedefined Code = "equ-redefined" // an EQU label defined more than once
+	EQUNoLabel   Code = "equ-no-label"  // an EQU which doesn't continue another one
+)
+
+// Diagnostic is a single problem found at Pos.
+type Diagnostic struct {
+	Severity Severity
+	Pos      lexer.Pos
+	Code     Code
+	Msg      string
+	Err      error // the error Msg comes from, if any
+}
+
+// Errorf returns an error diagnostic whose message is built just like
+// fmt.Errorf does. Any error wrapped with %w is kept in Err.
+func Errorf(pos lexer.Pos, code Code, format string, args ...any) *Diagnostic {
+	err := fmt.Errorf(format, args...)
+	return &Diagnostic{Severity: Error, Pos: pos, Code: code, Msg: err.Error(), Err: errors.Unwrap(err)}
+}
+
+// Error returns the message preceded by where it was found and, for
+// anything but errors, by the severity.
+func (d *Diagnostic) Error() string {
+	msg := d.Msg
+	if d.Severity != Error {
+		msg = d.Severity.String() + ": " + msg
+	}
+	if d.Pos == (lexer.Pos{}) {
+		return msg
+	}
+	return d.Pos.String() + ": " + msg
+}
+
+// Unwrap lets errors.Is and errors.As look into Err.
+func (d *Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+// List is a list of diagnostics. The zero List is ready to use.
+type List []*Diagnostic
+
+// Add appends d to the list.
+func (l *List) Add(d *Diagnostic) {
+	*l = append(*l, d)
+}
+
+// Errorf appends an error diagnostic to the list. See the function
+// with the same name.
+func (l *List) Errorf(pos lexer.Pos, code Code, format string, args ...any) {
+	l.Add(Errorf(pos, code, format, args...))
+}
+
+// Sort sorts the list by position. Diagnostics found at the same spot
+// keep their order.
+func (l List) Sort() {
+	slices.SortStableFunc(l, func(a, b *Diagnostic) int {
+		return cmp.Or(
+			cmp.Compare(a.Pos.File, b.Pos.File),
+			cmp.Compare(a.Pos.Line, b.Pos.Line),
+			cmp.Compare(a.Pos.Column, b.Pos.Column),
+		)
+	})
+}
+
+// Errors returns the number of diagnostics with the Error severity.
+func (l List) Errors() int {
+	n := 0
+	for _, d := range l {
+		if d.Severity == Error {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns the list as an error if it holds any errors and nil
+// otherwise: warnings alone aren't worth failing over.
+func (l List) Err() error {
+	if l.Errors() == 0 {
+		return nil
+	}
+	return l
+}
+
+// Error returns every diagnostic, one per line.
+func (l List) Error() string {
+	msgs := make([]string, len(l))
+	for i, d := range l {
+		msgs[i] = d.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap lets errors.Is and errors.As look into every diagnostic.
+func (l List) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, d := range l {
+		errs[i] = d
+	}
+	return errs
+}
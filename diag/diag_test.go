@@ -0,0 +1,67 @@
+package diag
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pcolladosoto/corewarg/lexer"
+)
+
+var errBoom = errors.New("boom")
+
+func pos(line, column int) lexer.Pos {
+	return lexer.Pos{File: "diagTest", Line: line, Column: column}
+}
+
+func TestDiagnosticError(t *testing.T) {
+	tests := []struct {
+		d    *Diagnostic
+		want string
+	}{
+		{Errorf(pos(3, 7), Syntax, "unexpected %s", "EOL"), "diagTest:3:7: unexpected EOL"},
+		{&Diagnostic{Severity: Warning, Pos: pos(1, 1), Msg: "odd"}, "diagTest:1:1: warning: odd"},
+		{&Diagnostic{Msg: "nowhere"}, "nowhere"},
+	}
+	for i, test := range tests {
+		if got := test.d.Error(); got != test.want {
+			t.Errorf("test %d: got %q, want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	var l List
+	if l.Err() != nil {
+		t.Errorf("empty list: got error %v", l.Err())
+	}
+
+	l.Add(&Diagnostic{Severity: Warning, Pos: pos(2, 1), Msg: "careful"})
+	if l.Err() != nil {
+		t.Errorf("warnings only: got error %v", l.Err())
+	}
+
+	l.Errorf(pos(4, 2), BadToken, "second")
+	l.Errorf(pos(1, 9), EQUCycle, "%w: first", errBoom)
+	l.Errorf(pos(4, 2), Syntax, "third")
+	l.Sort()
+
+	err := l.Err()
+	want := "diagTest:1:9: boom: first\n" +
+		"diagTest:2:1: warning: careful\n" +
+		"diagTest:4:2: second\n" +
+		"diagTest:4:2: third"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %q, want %q", err, want)
+	}
+	if l.Errors() != 3 {
+		t.Errorf("got %d errors, want 3", l.Errors())
+	}
+	if !errors.Is(err, errBoom) {
+		t.Errorf("%v doesn't wrap %v", err, errBoom)
+	}
+
+	var d *Diagnostic
+	if !errors.As(err, &d) || d.Code != EQUCycle {
+		t.Errorf("got diagnostic %+v, want the EQU cycle", d)
+	}
+}
@@ -0,0 +1,233 @@
+// Package format rewrites Redcode warriors in canonical form, much like
+// gofmt does for Go. Every line is split into four columns which are then
+// aligned throughout the warrior: labels, the opcode and its modifier, the
+// operands and the trailing comment. Opcodes and modifiers are upper-cased,
+// modes are glued to their operand and operands are separated by ", ".
+// Comments and blank lines are kept, although runs of blank lines are
+// collapsed into a single one. Lines end in LFs whichever EOLs the source
+// used. Anything after END is copied verbatim.
+//
+// Formatting is idempotent: formatting an already formatted warrior
+// leaves it untouched.
+package format
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pcolladosoto/corewarg/lexer"
+	"github.com/pcolladosoto/corewarg/parser"
+)
+
+// line is a line of source split into columns. Blank lines and full-line
+// comments aren't code.
+type line struct {
+	code       bool
+	labels     string
+	op         string // opcode and modifier
+	operands   string
+	comment    string // without the leading ';'
+	hasComment bool
+	indented   bool // whether the line begins with whitespace in the source
+}
+
+// Source formats the warrior in src. The name is only used in error
+// reports: src must be a valid warrior for it to be formatted.
+func Source(name string, src []byte) ([]byte, error) {
+	if _, err := parser.ParseString(name, string(src)); err != nil {
+		return nil, err
+	}
+
+	lines, rest := split(name, string(src))
+	return []byte(render(lines) + rest), nil
+}
+
+// split lexes src into lines up to and including END. Whatever comes after
+// END is returned untouched, as the lexer needn't even make sense of it.
+func split(name, src string) ([]line, string) {
+	raw := SplitLines(src)
+	l := lexer.Lex(name, src)
+
+	lines, items := []line{}, []lexer.Item{}
+	last := 0 // line number of the last line
+	for {
+		item := l.NextItem()
+		if len(items) == 0 && item.Line > last+1 {
+			// the lexer skips blank lines altogether
+			lines = append(lines, line{})
+		}
+
+		switch item.Typ {
+		case lexer.ItemEOF, lexer.ItemError:
+			if len(items) > 0 {
+				lines = append(lines, newLine(items, raw[items[0].Line-1]))
+			}
+			return lines, ""
+
+		case lexer.ItemEOL:
+			current := newLine(items, raw[item.Line-1])
+			lines = append(lines, current)
+			items, last = items[:0], item.Line
+			if strings.EqualFold(current.op, parser.END.String()) {
+				return lines, strings.Join(raw[item.Line:], "")
+			}
+
+		default:
+			items = append(items, item)
+		}
+	}
+}
+
+// SplitLines splits src after every EOL, telling them apart just like the
+// lexer does: LF, CR, LF CR and CR LF all end a single line. Every line
+// keeps its EOL, and the last one holds whatever follows the final EOL.
+func SplitLines(src string) []string {
+	lines := []string{}
+	for {
+		i := strings.IndexAny(src, "\n\r")
+		if i == -1 {
+			return append(lines, src)
+		}
+		if i+1 < len(src) && strings.IndexByte("\n\r", src[i+1]) != -1 && src[i+1] != src[i] {
+			i++
+		}
+		lines, src = append(lines, src[:i+1]), src[i+1:]
+	}
+}
+
+func newLine(items []lexer.Item, raw string) line {
+	ln := line{indented: strings.IndexFunc(raw, unicode.IsSpace) == 0}
+
+	if n := len(items); n > 0 && items[n-1].Typ == lexer.ItemComment {
+		ln.comment, ln.hasComment = strings.TrimRightFunc(items[n-1].Val, unicode.IsSpace), true
+		items = items[:n-1]
+	}
+	if len(items) == 0 {
+		return ln
+	}
+	ln.code = true
+
+	labels := []string{}
+	for len(items) > 0 && items[0].Typ == lexer.ItemLabel {
+		labels = append(labels, items[0].Val)
+		items = items[1:]
+	}
+	ln.labels = strings.Join(labels, " ")
+
+	if len(items) > 0 && items[0].Typ == lexer.ItemOpcode {
+		ln.op = strings.ToUpper(items[0].Val)
+		items = items[1:]
+		if len(items) > 0 && items[0].Typ == lexer.ItemOpcodeModifier {
+			ln.op += "." + strings.ToUpper(items[0].Val)
+			items = items[1:]
+		}
+	}
+	ln.operands = join(items)
+
+	return ln
+}
+
+// join prints items back as text. Items are glued together unless that
+// would merge two words, and commas are followed by a space. The body of
+// an EQU can hold whole instructions, so opcodes and modifiers are
+// handled as well.
+func join(items []lexer.Item) string {
+	var b strings.Builder
+	for i, item := range items {
+		switch item.Typ {
+		case lexer.ItemComma:
+			b.WriteString(", ")
+			continue
+		case lexer.ItemOpcodeModifier:
+			b.WriteString("." + strings.ToUpper(item.Val))
+			continue
+		}
+
+		if i > 0 && (isWord(items[i-1]) && isWord(item) || isOperation(items[i-1])) {
+			b.WriteString(" ")
+		}
+		if item.Typ == lexer.ItemOpcode {
+			b.WriteString(strings.ToUpper(item.Val))
+		} else {
+			b.WriteString(item.Val)
+		}
+	}
+	return b.String()
+}
+
+func isWord(item lexer.Item) bool {
+	switch item.Typ {
+	case lexer.ItemLabel, lexer.ItemNumber, lexer.ItemOpcode:
+		return true
+	}
+	return false
+}
+
+func isOperation(item lexer.Item) bool {
+	return item.Typ == lexer.ItemOpcode || item.Typ == lexer.ItemOpcodeModifier
+}
+
+// render aligns the columns of every line of code. Full-line comments go
+// at the beginning of the line unless they were indented to carry on with
+// the trailing comment of the line above: those stay in the comment column.
+func render(lines []line) string {
+	labelW, opW, operandsW := 0, 0, 0
+	for _, ln := range lines {
+		if ln.code {
+			labelW = max(labelW, utf8.RuneCountInString(ln.labels))
+			opW = max(opW, utf8.RuneCountInString(ln.op))
+			operandsW = max(operandsW, utf8.RuneCountInString(ln.operands))
+		}
+	}
+
+	columns := func(ln line) string {
+		cols := []string{}
+		if labelW > 0 {
+			cols = append(cols, pad(ln.labels, labelW))
+		}
+		cols = append(cols, pad(ln.op, opW))
+		if operandsW > 0 {
+			cols = append(cols, pad(ln.operands, operandsW))
+		}
+		return strings.Join(cols, " ") + " "
+	}
+	commentCol := utf8.RuneCountInString(columns(line{}))
+
+	var b strings.Builder
+	blank, continues := false, false
+	for _, ln := range lines {
+		switch {
+		case !ln.code && !ln.hasComment:
+			// only leave blank lines in between other lines
+			blank = b.Len() > 0
+			continues = false
+			continue
+		case blank:
+			b.WriteString("\n")
+			blank = false
+		}
+
+		out := ""
+		switch {
+		case ln.code:
+			out = columns(ln)
+			continues = ln.hasComment
+		case ln.indented && continues:
+			out = strings.Repeat(" ", commentCol)
+		default:
+			continues = false
+		}
+		if ln.hasComment {
+			out += ";" + ln.comment
+		}
+		b.WriteString(strings.TrimRightFunc(out, unicode.IsSpace) + "\n")
+	}
+	return b.String()
+}
+
+// pad fills s with spaces up to width. Widths are counted in runes so
+// that labels beyond ASCII line up too.
+func pad(s string, width int) string {
+	return s + strings.Repeat(" ", width-utf8.RuneCountInString(s))
+}
@@ -0,0 +1,113 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"mov 0,1\n", "MOV 0, 1\n"},
+		{"  mov.i   $0 ,  $1", "MOV.I $0, $1\n"},
+		{
+			"imp mov.i 0, 1\nDAT #0\n",
+			"imp MOV.I 0, 1\n    DAT   #0\n",
+		},
+		{
+			"a  JMP  a ; loop\n  spl.b #-( a + 1 ) * 2,  * a\n",
+			"a JMP   a             ; loop\n  SPL.B #-(a+1)*2, *a\n",
+		},
+		{
+			"\n\n; header\n\n\n\nDAT 0 ;bomb\n     ; more about it\n; unrelated\n\n",
+			"; header\n\nDAT 0 ;bomb\n      ; more about it\n; unrelated\n",
+		},
+		{
+			"foo bar MOV 0, 1\nbaz\nqux DAT 0\n",
+			"foo bar MOV 0, 1\nbaz\nqux     DAT 0\n",
+		},
+		{
+			"dec EQU sub.ab #1,cnt\n    EQU jmn loop , cnt\nloop dec\ncnt dat 0, 5\n",
+			// the lexer can't tell using an EQU from labelling a line
+			"dec      EQU SUB.AB #1, cnt\n         EQU JMN loop, cnt\nloop dec\ncnt      DAT 0, 5\n",
+		},
+		{
+			"; windows\r\n\r\nJMP 0 ;x\r\n  ; y\r\n; mac\rDAT 0\r\rDAT 1\n\r",
+			"; windows\n\nJMP 0 ;x\n      ; y\n; mac\nDAT 0\n\nDAT 1\n",
+		},
+		{
+			"DAT 0\r\nEND\r\nstays\rjust\r\nas is\n\r",
+			"DAT 0\nEND\nstays\rjust\r\nas is\n\r",
+		},
+		{
+			"DAT 0\nend ; done\nthis is !! not Redcode\n",
+			"DAT 0\nEND   ; done\nthis is !! not Redcode\n",
+		},
+		{"DAT 0\n  END", "DAT 0\nEND\n"},
+		{"MOV 0,1\n; trailing", "MOV 0, 1\n; trailing\n"},
+		{"mov 0,1 ; trailing", "MOV 0, 1 ; trailing\n"},
+		{"ñañá MOV 0, 1\nb DAT 0 ; bomb\n", "ñañá MOV 0, 1\nb    DAT 0    ; bomb\n"},
+	}
+
+	for i, test := range tests {
+		got, err := Source("formatTest", []byte(test.in))
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("test %d: got\n%s\nwant\n%s", i, got, test.want)
+		}
+
+		again, err := Source("formatTest", got)
+		if err != nil {
+			t.Errorf("test %d: unexpected error formatting again: %v", i, err)
+			continue
+		}
+		if string(again) != string(got) {
+			t.Errorf("test %d: formatting isn't idempotent: got\n%s\nand then\n%s", i, got, again)
+		}
+	}
+}
+
+func TestSourceFiles(t *testing.T) {
+	paths, err := filepath.Glob("../lexer/testdata/*.rc")
+	if err != nil {
+		t.Fatalf("error listing files: %v", err)
+	}
+	corpus, err := filepath.Glob("../lexer/testdata/corpus/*.rc")
+	if err != nil {
+		t.Fatalf("error listing files: %v", err)
+	}
+
+	for _, path := range append(paths, corpus...) {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("error reading %q: %v", path, err)
+		}
+
+		once, err := Source(path, src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", path, err)
+			continue
+		}
+		twice, err := Source(path, once)
+		if err != nil {
+			t.Errorf("%s: unexpected error formatting again: %v", path, err)
+			continue
+		}
+		if string(once) != string(twice) {
+			t.Errorf("%s: formatting isn't idempotent: got\n%s\nand then\n%s", path, once, twice)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	for i, in := range []string{"MOV 0,\n", "MOV.Q 0, 1\n", "5 WRONG 4\n", "a EQU a\n"} {
+		if _, err := Source("formatTest", []byte(in)); err == nil {
+			t.Errorf("test %d (%q): formatted it and it shouldn't...", i, in)
+		}
+	}
+}
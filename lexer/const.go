@@ -6,6 +6,12 @@ import "fmt"
 type Item struct {
 	Typ ItemType
 	Val string
+	Pos // where the item starts
+}
+
+// Span returns the stretch of source the item was scanned from.
+func (i Item) Span() Span {
+	return Span{Start: i.Pos, End: i.Pos.advance(i.Val)}
 }
 
 func (i Item) String() string {
@@ -31,9 +37,10 @@ const (
 	ItemLabel                          // an instruction label (i.e. an alphanumeric)
 	ItemOpcode                         // an instruction opcode (i.e. DAT, MOV, ADD, ...)
 	ItemOpcodeModifier                 // an instruction modifier (i.e. A, B, AB, BA, F, X, I)
-	ItemAddressingMode                 // an instruction addressing mode (i.e. #, $, @, <, >)
+	ItemAddressingMode                 // an instruction addressing mode (i.e. #, $, @, <, >, *, {, })
 	ItemNumber                         // an integer number
 	ItemOperand                        // a valid operand for an expression (i.e. +, -, *, /, %)
+	ItemComma                          // the separator between an instruction's fields
 )
 
 // Make the types prettyprint.
@@ -48,6 +55,7 @@ var itemName = map[ItemType]string{
 	ItemAddressingMode: "mode",
 	ItemNumber:         "number",
 	ItemOperand:        "operand",
+	ItemComma:          "comma",
 }
 
 var key = map[string]ItemType{
@@ -65,9 +73,15 @@ var key = map[string]ItemType{
 	"CMP": ItemOpcode,
 	"SLT": ItemOpcode,
 	"SPL": ItemOpcode,
+	"SEQ": ItemOpcode,
+	"SNE": ItemOpcode,
+	"NOP": ItemOpcode,
+	"LDP": ItemOpcode,
+	"STP": ItemOpcode,
 	"ORG": ItemOpcode,
 	"EQU": ItemOpcode,
 	"END": ItemOpcode,
+	"PIN": ItemOpcode,
 
 	"A":  ItemOpcodeModifier,
 	"B":  ItemOpcodeModifier,
@@ -82,6 +96,8 @@ var key = map[string]ItemType{
 	"@": ItemAddressingMode,
 	"<": ItemAddressingMode,
 	">": ItemAddressingMode,
+	"{": ItemAddressingMode,
+	"}": ItemAddressingMode,
 
 	"+": ItemOperand,
 	"-": ItemOperand,
@@ -90,6 +106,8 @@ var key = map[string]ItemType{
 	"%": ItemOperand,
 	"(": ItemOperand,
 	")": ItemOperand,
+
+	",": ItemComma,
 }
 
 func (i ItemType) String() string {
@@ -32,13 +32,14 @@ The language grammar is defined as:
 	opcode:
 		DAT | MOV | ADD | SUB | MUL | DIV | MOD |
 		JMP | JMZ | JMN | DJN | CMP | SLT | SPL |
-		ORG | EQU | END
+		SEQ | SNE | NOP | LDP | STP |
+		ORG | EQU | END | PIN
 
 	modifier:
 		A | B | AB | BA | F | X | I
 
 	mode:
-		# | $ | @ | < | > | e
+		# | $ | @ | < | > | * | { | } | e
 
 	expr:
 		term |
@@ -86,6 +87,27 @@ more occurrences of the previous token, and a plus '+' immediately
 adjacent means one or more occurrences of the previous token. The
 vertical bar '|' means OR.
 
+On top of the ICWS'94 draft, the opcodes SEQ, SNE, NOP, LDP and STP and
+the PIN pseudo-opcode from the pMARS extensions are supported as well,
+and so are the A-field addressing modes '*', '{' and '}'. An asterisk is
+only taken to be a mode when it begins an operand: everywhere else it's
+a multiplication. Just like in pMARS, opcodes and modifiers are case
+insensitive, and modifiers are only recognised right after an opcode's
+dot so that they can be used as labels too.
+
+Every item records where it begins as a Pos: the name of the input, the
+byte offset and the line and column numbers. Its Span stretches up to the
+position right after it, which is what the parser builds the spans of
+instructions, operands and terms out of.
+
+Errors don't bring lexing to a halt: an ItemError is followed by the EOL
+of the offending line, whose remains are dropped, and lexing carries on
+with the next one.
+
+Lex scans a string. LexReader reads its input from an io.Reader instead,
+holding on to little more than the item being scanned, so that large
+inputs such as whole hill archives are lexed in linear time and space.
+
 This lexer is intended to be used together with the goyacc-based parser
 provided by the accompanying parser package.
 
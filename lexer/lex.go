@@ -8,24 +8,49 @@ package lexer
 
 import (
 	"fmt"
+	"io"
 	"strings"
 	"unicode/utf8"
 )
 
-// lexer holds the state of the scanner.
+// lexer holds the state of the scanner. Rather than running on a goroutine
+// of its own and sending items down a channel, it's driven by NextItem,
+// which runs states until one emits something. Items wait in a small ring
+// until they're asked for.
+//
+// When reading from an io.Reader, input only holds a window of it: the
+// item being scanned and whatever has been read past it. Reading more
+// drops everything before start, so memory doesn't grow with the input.
 type Lexer struct {
-	name  string    // the name of the input; used only for error reports.
-	input string    // the string being scanned.
-	state stateFn   // the next lexing function to enter
-	pos   int       // current position in the input.
-	start int       // start position of this item.
-	width int       // width of last rune read from input.
-	items chan Item // channel of scanned items.
+	name  string         // the name of the input; used only for error reports.
+	input string         // the string being scanned, or a window of it.
+	r     io.Reader      // where the rest of the input comes from, if anywhere.
+	buf   []byte         // buffer for reads from r.
+	err   error          // the error which stopped reads from r, if any.
+	state stateFn        // the next lexing function to enter
+	pos   int            // current position in the input.
+	start int            // start position of this item.
+	where Pos            // line and column of start.
+	width int            // width of last rune read from input.
+	items [ringSize]Item // ring of scanned items.
+	head  int            // index of the next item to hand out.
+	n     int            // number of items in the ring.
+	last  ItemType       // type of the last emitted item.
 }
 
+// ringSize is how many items can be waiting. States emit one or two items
+// at most before going back to NextItem: think of a comment and its EOL.
+const ringSize = 4
+
+// readSize is how much input is read from an io.Reader at once.
+const readSize = 32 * 1024
+
 // next returns the next rune in the input.
 func (l *Lexer) next() rune {
 	var r rune
+	if l.r != nil && !utf8.FullRuneInString(l.input[l.pos:]) {
+		l.fill()
+	}
 	if l.pos >= len(l.input) {
 		l.width = 0
 		return eof
@@ -35,6 +60,27 @@ func (l *Lexer) next() rune {
 	return r
 }
 
+// fill reads from r until there's a whole rune past pos or the input is
+// over. What lies before start has been emitted or ignored already, so
+// it's dropped along the way. Runes split across reads are put back
+// together too.
+func (l *Lexer) fill() {
+	for l.r != nil && !utf8.FullRuneInString(l.input[l.pos:]) {
+		n, err := l.r.Read(l.buf)
+		if n > 0 {
+			l.input = l.input[l.start:] + string(l.buf[:n])
+			l.pos -= l.start
+			l.start = 0
+		}
+		if err != nil {
+			if err != io.EOF {
+				l.err = err
+			}
+			l.r = nil
+		}
+	}
+}
+
 // peek returns but does not consume the next rune in the input.
 func (l *Lexer) peek() rune {
 	r := l.next()
@@ -49,12 +95,24 @@ func (l *Lexer) backup() {
 
 // emit passes an item back to the client.
 func (l *Lexer) emit(t ItemType) {
-	l.items <- Item{t, l.input[l.start:l.pos]}
-	l.start = l.pos
+	l.push(Item{Typ: t, Val: l.input[l.start:l.pos], Pos: l.where})
+	l.ignore()
+	l.last = t
+}
+
+// push queues an item until NextItem hands it out.
+func (l *Lexer) push(item Item) {
+	if l.n == len(l.items) {
+		panic("lexer: too many items emitted in a single state")
+	}
+	l.items[(l.head+l.n)%len(l.items)] = item
+	l.n++
 }
 
-// ignore skips over the pending input before this point.
+// ignore skips over the pending input before this point. The position of
+// start is worked out as it goes, so that peek can't double count lines.
 func (l *Lexer) ignore() {
+	l.where = l.where.advance(l.input[l.start:l.pos])
 	l.start = l.pos
 }
 
@@ -74,29 +132,54 @@ func (l *Lexer) acceptRun(valid string) {
 	l.backup()
 }
 
-// lineNumber reports which line we're on. Doing it this way
-// means we don't have to worry about peek double counting.
-func (l *Lexer) lineNumber() int {
-	return 1 + strings.Count(l.input[:l.pos], "\n")
+// acceptEOL consumes the rest of the EOL beginning with r, which has just
+// been read: an LF followed by a CR and a CR followed by an LF make up a
+// single EOL, so that files saved anywhere have the same number of lines.
+func (l *Lexer) acceptEOL(r rune) {
+	if n := l.peek(); isEOL(n) && n != r {
+		l.next()
+	}
+}
+
+// atOperand reports whether the next item begins an operand. That's where
+// an addressing mode may appear, which tells '*' as a mode from '*' as the
+// multiplication operator.
+func (l *Lexer) atOperand() bool {
+	switch l.last {
+	case ItemOpcode, ItemOpcodeModifier, ItemComma:
+		return true
+	}
+	return false
 }
 
-// error returns an error token and terminates the scan by passing
-// back a nil pointer that will be the next state, terminating l.run.
+// error returns an error token and skips the rest of the line, so that
+// the scan carries on with the next one. That way a single typo doesn't
+// hide every problem after it.
 func (l *Lexer) errorf(format string, args ...interface{}) stateFn {
-	l.items <- Item{ItemError, fmt.Sprintf(format, args...)}
-	return nil
+	l.push(Item{Typ: ItemError, Val: fmt.Sprintf(format, args...), Pos: l.where})
+	return lexSkipLine
 }
 
-// nextItem returns the next item from the input.
+// NextItem returns the next item from the input. Once the input is over
+// it keeps on returning EOF.
 func (l *Lexer) NextItem() Item {
-	for {
-		select {
-		case item := <-l.items:
-			return item
-		default:
-			l.state = l.state(l)
+	for l.n == 0 {
+		if l.state == nil {
+			return Item{Typ: ItemEOF, Pos: l.where}
 		}
+		l.state = l.state(l)
 	}
+	item := l.items[l.head]
+	l.head = (l.head + 1) % len(l.items)
+	l.n--
+	return item
+}
+
+// Err returns the error which cut reading the input short, if any. The
+// lexer emits EOF in that case, just as if the input had come to an end.
+// Running out of input isn't an error.
+func (l *Lexer) Err() error {
+	return l.err
 }
 
 // lex creates a new scanner for the input string.
@@ -105,7 +188,17 @@ func Lex(name, input string) *Lexer {
 		name:  name,
 		input: input,
 		state: lexLine,
-		items: make(chan Item, 2), // Two items sufficient.
+		where: Pos{File: name, Line: 1, Column: 1},
 	}
 	return l
 }
+
+// LexReader creates a new scanner reading its input from r as it goes,
+// which is how inputs too large to hold in memory (e.g. whole hill
+// archives) can be lexed. Item values are just as with Lex.
+func LexReader(name string, r io.Reader) *Lexer {
+	l := Lex(name, "")
+	l.r = r
+	l.buf = make([]byte, readSize)
+	return l
+}
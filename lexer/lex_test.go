@@ -1,11 +1,15 @@
 package lexer
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func init() {
@@ -29,9 +33,16 @@ func init() {
 	slog.SetDefault(logger)
 }
 
+// item is what we compare lexed items against: where they
+// come from is checked on its own in TestLexLines.
+type item struct {
+	Typ ItemType
+	Val string
+}
+
 type tests []struct {
 	in   string
-	want []Item
+	want []item
 }
 
 func runTests(t *testing.T, ts tests) {
@@ -66,9 +77,10 @@ func TestLexLineComments(t *testing.T) {
 		{"", nil},
 		{"\n", nil},
 		{"\n\n", nil},
-		{"; this is a comment", nil}, // we need "\n" terminations
-		{"; this is a comment\n", []Item{{ItemComment, " this is a comment"}, {ItemEOL, "\n"}}},
-		{";this is a comment too\n", []Item{{ItemComment, "this is a comment too"}, {ItemEOL, "\n"}}},
+		{"; this is a comment", []item{{ItemComment, " this is a comment"}}}, // no EOL needed
+		{"DAT 0 ;", []item{{ItemOpcode, "DAT"}, {ItemNumber, "0"}, {ItemComment, ""}}},
+		{"; this is a comment\n", []item{{ItemComment, " this is a comment"}, {ItemEOL, "\n"}}},
+		{";this is a comment too\n", []item{{ItemComment, "this is a comment too"}, {ItemEOL, "\n"}}},
 	}
 
 	runTests(t, ts)
@@ -76,18 +88,71 @@ func TestLexLineComments(t *testing.T) {
 
 func TestLexSingleInstruction(t *testing.T) {
 	ts := tests{
-		{"target  DAT.F   #0,     #0", []Item{{ItemLabel, "target"}, {ItemOpcode, "DAT"}, {ItemOpcodeModifier, "F"}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}}},
-		{"target  DAT.F   #-5,   #15", []Item{{ItemLabel, "target"}, {ItemOpcode, "DAT"}, {ItemOpcodeModifier, "F"}, {ItemAddressingMode, "#"}, {ItemOperand, "-"}, {ItemNumber, "5"}, {ItemAddressingMode, "#"}, {ItemNumber, "15"}}},
-		{"ADD.AB  #step,   target", []Item{{ItemOpcode, "ADD"}, {ItemOpcodeModifier, "AB"}, {ItemAddressingMode, "#"}, {ItemLabel, "step"}, {ItemLabel, "target"}}},
-		{"MOV.AB  #0,     @target", []Item{{ItemOpcode, "MOV"}, {ItemOpcodeModifier, "AB"}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}, {ItemAddressingMode, "@"}, {ItemLabel, "target"}}},
-		{"JMP.A    start", []Item{{ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
-		{"ORG     start", []Item{{ItemOpcode, "ORG"}, {ItemLabel, "start"}}},
-		{"END", []Item{{ItemOpcode, "END"}}},
-		{"step    EQU      4", []Item{{ItemLabel, "step"}, {ItemOpcode, "EQU"}, {ItemNumber, "4"}}},
-		{"JMP.A    start ; foo", []Item{{ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
-		{"foo fii JMP.A    start ; foo", []Item{{ItemLabel, "foo"}, {ItemLabel, "fii"}, {ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
-		{"foo\nfii JMP.A    start", []Item{{ItemLabel, "foo"}, {ItemEOL, "\n"}, {ItemLabel, "fii"}, {ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
-		{"\n\t\nfoo\nfii\t JMP.A  \t  start", []Item{{ItemLabel, "foo"}, {ItemEOL, "\n"}, {ItemLabel, "fii"}, {ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
+		{"target  DAT.F   #0,     #0", []item{{ItemLabel, "target"}, {ItemOpcode, "DAT"}, {ItemOpcodeModifier, "F"}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}, {ItemComma, ","}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}}},
+		{"target  DAT.F   #-5,   #15", []item{{ItemLabel, "target"}, {ItemOpcode, "DAT"}, {ItemOpcodeModifier, "F"}, {ItemAddressingMode, "#"}, {ItemOperand, "-"}, {ItemNumber, "5"}, {ItemComma, ","}, {ItemAddressingMode, "#"}, {ItemNumber, "15"}}},
+		{"ADD.AB  #step,   target", []item{{ItemOpcode, "ADD"}, {ItemOpcodeModifier, "AB"}, {ItemAddressingMode, "#"}, {ItemLabel, "step"}, {ItemComma, ","}, {ItemLabel, "target"}}},
+		{"MOV.AB  #0,     @target", []item{{ItemOpcode, "MOV"}, {ItemOpcodeModifier, "AB"}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}, {ItemComma, ","}, {ItemAddressingMode, "@"}, {ItemLabel, "target"}}},
+		{"JMP.A    start", []item{{ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
+		{"ORG     start", []item{{ItemOpcode, "ORG"}, {ItemLabel, "start"}}},
+		{"END", []item{{ItemOpcode, "END"}}},
+		{"step    EQU      4", []item{{ItemLabel, "step"}, {ItemOpcode, "EQU"}, {ItemNumber, "4"}}},
+		{"JMP.A    start ; foo", []item{{ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}, {ItemComment, " foo"}}},
+		{"foo fii JMP.A    start ; foo", []item{{ItemLabel, "foo"}, {ItemLabel, "fii"}, {ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}, {ItemComment, " foo"}}},
+		{"foo\nfii JMP.A    start", []item{{ItemLabel, "foo"}, {ItemEOL, "\n"}, {ItemLabel, "fii"}, {ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
+		{"\n\t\nfoo\nfii\t JMP.A  \t  start", []item{{ItemLabel, "foo"}, {ItemEOL, "\n"}, {ItemLabel, "fii"}, {ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
+	}
+
+	runTests(t, ts)
+}
+
+func TestLexExtendedOpcodes(t *testing.T) {
+	ts := tests{
+		{"SEQ.I 0, 1", []item{{ItemOpcode, "SEQ"}, {ItemOpcodeModifier, "I"}, {ItemNumber, "0"}, {ItemComma, ","}, {ItemNumber, "1"}}},
+		{"SNE 0, 1", []item{{ItemOpcode, "SNE"}, {ItemNumber, "0"}, {ItemComma, ","}, {ItemNumber, "1"}}},
+		{"loop NOP", []item{{ItemLabel, "loop"}, {ItemOpcode, "NOP"}}},
+		{"LDP.AB #0, res", []item{{ItemOpcode, "LDP"}, {ItemOpcodeModifier, "AB"}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}, {ItemComma, ","}, {ItemLabel, "res"}}},
+		{"STP.B res, #1", []item{{ItemOpcode, "STP"}, {ItemOpcodeModifier, "B"}, {ItemLabel, "res"}, {ItemComma, ","}, {ItemAddressingMode, "#"}, {ItemNumber, "1"}}},
+	}
+
+	runTests(t, ts)
+}
+
+func TestLexExtendedModes(t *testing.T) {
+	ts := tests{
+		{"MOV *1, {2", []item{{ItemOpcode, "MOV"}, {ItemAddressingMode, "*"}, {ItemNumber, "1"}, {ItemComma, ","}, {ItemAddressingMode, "{"}, {ItemNumber, "2"}}},
+		{"MOV.I }a, *b", []item{{ItemOpcode, "MOV"}, {ItemOpcodeModifier, "I"}, {ItemAddressingMode, "}"}, {ItemLabel, "a"}, {ItemComma, ","}, {ItemAddressingMode, "*"}, {ItemLabel, "b"}}},
+		{"MOV 2*3, *2*3", []item{
+			{ItemOpcode, "MOV"}, {ItemNumber, "2"}, {ItemOperand, "*"}, {ItemNumber, "3"}, {ItemComma, ","},
+			{ItemAddressingMode, "*"}, {ItemNumber, "2"}, {ItemOperand, "*"}, {ItemNumber, "3"},
+		}},
+	}
+
+	runTests(t, ts)
+}
+
+func TestLexCase(t *testing.T) {
+	ts := tests{
+		{"mov.ab #1, a", []item{{ItemOpcode, "mov"}, {ItemOpcodeModifier, "ab"}, {ItemAddressingMode, "#"}, {ItemNumber, "1"}, {ItemComma, ","}, {ItemLabel, "a"}}},
+		{"x Jmp.B i", []item{{ItemLabel, "x"}, {ItemOpcode, "Jmp"}, {ItemOpcodeModifier, "B"}, {ItemLabel, "i"}}},
+		{"movie dat 0", []item{{ItemLabel, "movie"}, {ItemOpcode, "dat"}, {ItemNumber, "0"}}},
+		{"MOV.Q 0", []item{{ItemOpcode, "MOV"}, {ItemError, `bad modifier: "Q"`}}},
+	}
+
+	runTests(t, ts)
+}
+
+// TestLexErrors checks that lexing carries on with the next line after
+// an error, dropping whatever was left of the broken one.
+func TestLexErrors(t *testing.T) {
+	ts := tests{
+		{"MOV 0 & 1 ; gone\nDAT 1\n", []item{
+			{ItemOpcode, "MOV"}, {ItemNumber, "0"}, {ItemError, `unexpected character '&'`}, {ItemEOL, "\n"},
+			{ItemOpcode, "DAT"}, {ItemNumber, "1"}, {ItemEOL, "\n"},
+		}},
+		{"DAT 12ab\nJMP.Z 0\n", []item{
+			{ItemOpcode, "DAT"}, {ItemError, `bad number syntax: "12a"`}, {ItemEOL, "\n"},
+			{ItemOpcode, "JMP"}, {ItemError, `bad modifier: "Z"`}, {ItemEOL, "\n"},
+		}},
 	}
 
 	runTests(t, ts)
@@ -95,18 +160,18 @@ func TestLexSingleInstruction(t *testing.T) {
 
 func TestLexTwoInstructions(t *testing.T) {
 	ts := tests{
-		{"target  DAT.F   #0,     #0", []Item{{ItemLabel, "target"}, {ItemOpcode, "DAT"}, {ItemOpcodeModifier, "F"}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}}},
-		{"target  DAT.F   #-5,   #15", []Item{{ItemLabel, "target"}, {ItemOpcode, "DAT"}, {ItemOpcodeModifier, "F"}, {ItemAddressingMode, "#"}, {ItemOperand, "-"}, {ItemNumber, "5"}, {ItemAddressingMode, "#"}, {ItemNumber, "15"}}},
-		{"ADD.AB  #step,   target", []Item{{ItemOpcode, "ADD"}, {ItemOpcodeModifier, "AB"}, {ItemAddressingMode, "#"}, {ItemLabel, "step"}, {ItemLabel, "target"}}},
-		{"MOV.AB  #0,     @target", []Item{{ItemOpcode, "MOV"}, {ItemOpcodeModifier, "AB"}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}, {ItemAddressingMode, "@"}, {ItemLabel, "target"}}},
-		{"JMP.A    start", []Item{{ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
-		{"ORG     start", []Item{{ItemOpcode, "ORG"}, {ItemLabel, "start"}}},
-		{"END", []Item{{ItemOpcode, "END"}}},
-		{"step    EQU      4", []Item{{ItemLabel, "step"}, {ItemOpcode, "EQU"}, {ItemNumber, "4"}}},
-		{"JMP.A    start ; foo", []Item{{ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
-		{"foo fii JMP.A    start ; foo", []Item{{ItemLabel, "foo"}, {ItemLabel, "fii"}, {ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
-		{"foo\nfii JMP.A    start", []Item{{ItemLabel, "foo"}, {ItemEOL, "\n"}, {ItemLabel, "fii"}, {ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
-		{"\n\t\nfoo\nfii\t JMP.A  \t  start", []Item{{ItemLabel, "foo"}, {ItemEOL, "\n"}, {ItemLabel, "fii"}, {ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
+		{"target  DAT.F   #0,     #0", []item{{ItemLabel, "target"}, {ItemOpcode, "DAT"}, {ItemOpcodeModifier, "F"}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}, {ItemComma, ","}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}}},
+		{"target  DAT.F   #-5,   #15", []item{{ItemLabel, "target"}, {ItemOpcode, "DAT"}, {ItemOpcodeModifier, "F"}, {ItemAddressingMode, "#"}, {ItemOperand, "-"}, {ItemNumber, "5"}, {ItemComma, ","}, {ItemAddressingMode, "#"}, {ItemNumber, "15"}}},
+		{"ADD.AB  #step,   target", []item{{ItemOpcode, "ADD"}, {ItemOpcodeModifier, "AB"}, {ItemAddressingMode, "#"}, {ItemLabel, "step"}, {ItemComma, ","}, {ItemLabel, "target"}}},
+		{"MOV.AB  #0,     @target", []item{{ItemOpcode, "MOV"}, {ItemOpcodeModifier, "AB"}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}, {ItemComma, ","}, {ItemAddressingMode, "@"}, {ItemLabel, "target"}}},
+		{"JMP.A    start", []item{{ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
+		{"ORG     start", []item{{ItemOpcode, "ORG"}, {ItemLabel, "start"}}},
+		{"END", []item{{ItemOpcode, "END"}}},
+		{"step    EQU      4", []item{{ItemLabel, "step"}, {ItemOpcode, "EQU"}, {ItemNumber, "4"}}},
+		{"JMP.A    start ; foo", []item{{ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}, {ItemComment, " foo"}}},
+		{"foo fii JMP.A    start ; foo", []item{{ItemLabel, "foo"}, {ItemLabel, "fii"}, {ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}, {ItemComment, " foo"}}},
+		{"foo\nfii JMP.A    start", []item{{ItemLabel, "foo"}, {ItemEOL, "\n"}, {ItemLabel, "fii"}, {ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
+		{"\n\t\nfoo\nfii\t JMP.A  \t  start", []item{{ItemLabel, "foo"}, {ItemEOL, "\n"}, {ItemLabel, "fii"}, {ItemOpcode, "JMP"}, {ItemOpcodeModifier, "A"}, {ItemLabel, "start"}}},
 	}
 
 	runTests(t, ts)
@@ -119,7 +184,18 @@ func TestLexFiles(t *testing.T) {
 		t.Fatalf("error reading dir contents: %v", err)
 	}
 
-	wants := map[string][]Item{
+	wants := map[string][]item{
+		"mixed.rc": {
+			{ItemComment, "name Mixed"}, {ItemEOL, "\r\n"},
+			{ItemComment, "author Anonymous"}, {ItemEOL, "\r"},
+			{ItemComment, "strategy every kind of EOL"}, {ItemEOL, "\n\r"},
+			{ItemLabel, "foo"}, {ItemEOL, "\r"},
+			{ItemLabel, "bar"}, {ItemOpcode, "MOV"}, {ItemOpcodeModifier, "I"}, {ItemLabel, "foo"}, {ItemComma, ","}, {ItemLabel, "bar"},
+			{ItemComment, " CR LF"}, {ItemEOL, "\r\n"},
+			{ItemOpcode, "JMP"}, {ItemLabel, "foo"}, {ItemComment, " CR"}, {ItemEOL, "\r"},
+			{ItemOpcode, "DAT"}, {ItemAddressingMode, "#"}, {ItemNumber, "0"}, {ItemComment, " LF CR"}, {ItemEOL, "\n\r"},
+			{ItemOpcode, "END"}, {ItemEOL, "\r\n"},
+		},
 		"dwarf.rc": {
 			{ItemComment, "redcode"},
 			{ItemEOL, "\n"},
@@ -164,6 +240,7 @@ func TestLexFiles(t *testing.T) {
 			{ItemOpcodeModifier, "F"},
 			{ItemAddressingMode, "#"},
 			{ItemNumber, "0"},
+			{ItemComma, ","},
 			{ItemAddressingMode, "#"},
 			{ItemNumber, "0"},
 			{ItemComment, " Pointer to target instruction."},
@@ -174,6 +251,7 @@ func TestLexFiles(t *testing.T) {
 			{ItemOpcodeModifier, "AB"},
 			{ItemAddressingMode, "#"},
 			{ItemLabel, "step"},
+			{ItemComma, ","},
 			{ItemLabel, "target"},
 			{ItemComment, " Increments pointer by step."},
 			{ItemEOL, "\n"},
@@ -182,6 +260,7 @@ func TestLexFiles(t *testing.T) {
 			{ItemOpcodeModifier, "AB"},
 			{ItemAddressingMode, "#"},
 			{ItemNumber, "0"},
+			{ItemComma, ","},
 			{ItemAddressingMode, "@"},
 			{ItemLabel, "target"},
 			{ItemComment, " Bombs target instruction."},
@@ -204,6 +283,9 @@ func TestLexFiles(t *testing.T) {
 	ts := tests{}
 
 	for _, file := range paths {
+		if file.IsDir() { // the corpus is for benchmarks
+			continue
+		}
 		fName := file.Name()
 
 		prog, err := os.ReadFile(fmt.Sprintf("%s/%s", dataDir, fName))
@@ -220,7 +302,7 @@ func TestLexFiles(t *testing.T) {
 
 		ts = append(ts, struct {
 			in   string
-			want []Item
+			want []item
 		}{in: string(prog), want: want})
 	}
 
@@ -229,22 +311,255 @@ func TestLexFiles(t *testing.T) {
 
 func TestLexOperands(t *testing.T) {
 	ts := tests{
-		{"a + b\n", []Item{{ItemLabel, "a"}, {ItemOperand, "+"}, {ItemLabel, "b"}, {ItemEOL, "\n"}}},
-		{"a + b + c\n", []Item{{ItemLabel, "a"}, {ItemOperand, "+"}, {ItemLabel, "b"}, {ItemOperand, "+"}, {ItemLabel, "c"}, {ItemEOL, "\n"}}},
-		{"a - b\n", []Item{{ItemLabel, "a"}, {ItemOperand, "-"}, {ItemLabel, "b"}, {ItemEOL, "\n"}}},
-		{"a - b - c\n", []Item{{ItemLabel, "a"}, {ItemOperand, "-"}, {ItemLabel, "b"}, {ItemOperand, "-"}, {ItemLabel, "c"}, {ItemEOL, "\n"}}},
-		{"a * b\n", []Item{{ItemLabel, "a"}, {ItemOperand, "*"}, {ItemLabel, "b"}, {ItemEOL, "\n"}}},
-		{"a * b * c\n", []Item{{ItemLabel, "a"}, {ItemOperand, "*"}, {ItemLabel, "b"}, {ItemOperand, "*"}, {ItemLabel, "c"}, {ItemEOL, "\n"}}},
-		{"a / b\n", []Item{{ItemLabel, "a"}, {ItemOperand, "/"}, {ItemLabel, "b"}, {ItemEOL, "\n"}}},
-		{"a / b / c\n", []Item{{ItemLabel, "a"}, {ItemOperand, "/"}, {ItemLabel, "b"}, {ItemOperand, "/"}, {ItemLabel, "c"}, {ItemEOL, "\n"}}},
-		{"a % b\n", []Item{{ItemLabel, "a"}, {ItemOperand, "%"}, {ItemLabel, "b"}, {ItemEOL, "\n"}}},
-		{"a % b % c\n", []Item{{ItemLabel, "a"}, {ItemOperand, "%"}, {ItemLabel, "b"}, {ItemOperand, "%"}, {ItemLabel, "c"}, {ItemEOL, "\n"}}},
-		{"a + (b)\n", []Item{{ItemLabel, "a"}, {ItemOperand, "+"}, {ItemOperand, "("}, {ItemLabel, "b"}, {ItemOperand, ")"}, {ItemEOL, "\n"}}},
-		{"a * (b + c)\n", []Item{
+		{"a + b\n", []item{{ItemLabel, "a"}, {ItemOperand, "+"}, {ItemLabel, "b"}, {ItemEOL, "\n"}}},
+		{"a + b + c\n", []item{{ItemLabel, "a"}, {ItemOperand, "+"}, {ItemLabel, "b"}, {ItemOperand, "+"}, {ItemLabel, "c"}, {ItemEOL, "\n"}}},
+		{"a - b\n", []item{{ItemLabel, "a"}, {ItemOperand, "-"}, {ItemLabel, "b"}, {ItemEOL, "\n"}}},
+		{"a - b - c\n", []item{{ItemLabel, "a"}, {ItemOperand, "-"}, {ItemLabel, "b"}, {ItemOperand, "-"}, {ItemLabel, "c"}, {ItemEOL, "\n"}}},
+		{"a * b\n", []item{{ItemLabel, "a"}, {ItemOperand, "*"}, {ItemLabel, "b"}, {ItemEOL, "\n"}}},
+		{"a * b * c\n", []item{{ItemLabel, "a"}, {ItemOperand, "*"}, {ItemLabel, "b"}, {ItemOperand, "*"}, {ItemLabel, "c"}, {ItemEOL, "\n"}}},
+		{"a / b\n", []item{{ItemLabel, "a"}, {ItemOperand, "/"}, {ItemLabel, "b"}, {ItemEOL, "\n"}}},
+		{"a / b / c\n", []item{{ItemLabel, "a"}, {ItemOperand, "/"}, {ItemLabel, "b"}, {ItemOperand, "/"}, {ItemLabel, "c"}, {ItemEOL, "\n"}}},
+		{"a % b\n", []item{{ItemLabel, "a"}, {ItemOperand, "%"}, {ItemLabel, "b"}, {ItemEOL, "\n"}}},
+		{"a % b % c\n", []item{{ItemLabel, "a"}, {ItemOperand, "%"}, {ItemLabel, "b"}, {ItemOperand, "%"}, {ItemLabel, "c"}, {ItemEOL, "\n"}}},
+		{"a + (b)\n", []item{{ItemLabel, "a"}, {ItemOperand, "+"}, {ItemOperand, "("}, {ItemLabel, "b"}, {ItemOperand, ")"}, {ItemEOL, "\n"}}},
+		{"a * (b + c)\n", []item{
 			{ItemLabel, "a"}, {ItemOperand, "*"}, {ItemOperand, "("}, {ItemLabel, "b"}, {ItemOperand, "+"}, {ItemLabel, "c"},
 			{ItemOperand, ")"}, {ItemEOL, "\n"}},
 		},
+		{"#-1, 0\n", []item{{ItemAddressingMode, "#"}, {ItemOperand, "-"}, {ItemNumber, "1"}, {ItemComma, ","}, {ItemNumber, "0"}, {ItemEOL, "\n"}}},
+		{"#-(a)\n", []item{{ItemAddressingMode, "#"}, {ItemOperand, "-"}, {ItemOperand, "("}, {ItemLabel, "a"}, {ItemOperand, ")"}, {ItemEOL, "\n"}}},
+		{"0,-1\n", []item{{ItemNumber, "0"}, {ItemComma, ","}, {ItemOperand, "-"}, {ItemNumber, "1"}, {ItemEOL, "\n"}}},
 	}
 
 	runTests(t, ts)
 }
+
+func TestLexLines(t *testing.T) {
+	in := "; header\n\n\nfoo\nbar  JMP 0\n\n  ; trailing\nDAT #1, #2\n"
+	want := []struct {
+		typ  ItemType
+		line int
+	}{
+		{ItemComment, 1}, {ItemEOL, 1},
+		{ItemLabel, 4}, {ItemEOL, 4},
+		{ItemLabel, 5}, {ItemOpcode, 5}, {ItemNumber, 5}, {ItemEOL, 5},
+		{ItemComment, 7}, {ItemEOL, 7},
+		{ItemOpcode, 8}, {ItemAddressingMode, 8}, {ItemNumber, 8}, {ItemComma, 8}, {ItemAddressingMode, 8}, {ItemNumber, 8}, {ItemEOL, 8},
+		{ItemEOF, 9},
+	}
+
+	l := Lex("lexTest", in)
+	for i, w := range want {
+		item := l.NextItem()
+		if item.Typ != w.typ || item.Line != w.line {
+			t.Errorf("item %d: got type: %s, line: %d; want type: %s, line: %d", i, item.Typ, item.Line, w.typ, w.line)
+		}
+	}
+}
+
+// TestLexLineEndings checks that LF, CR, LF CR and CR LF each end a single
+// line wherever they show up, and that they never leak into other items.
+func TestLexLineEndings(t *testing.T) {
+	tests := []struct {
+		in    string
+		lines []int // the line of each item
+		last  int   // the line of EOF
+	}{
+		{"DAT 0\nDAT 1\n", []int{1, 1, 1, 2, 2, 2}, 3},
+		{"DAT 0\r\nDAT 1\r\n", []int{1, 1, 1, 2, 2, 2}, 3},
+		{"DAT 0\rDAT 1\r", []int{1, 1, 1, 2, 2, 2}, 3},
+		{"DAT 0\n\rDAT 1\n\r", []int{1, 1, 1, 2, 2, 2}, 3},
+		{"DAT 0\n\nDAT 1", []int{1, 1, 1, 3, 3}, 3},
+		{"DAT 0\r\rDAT 1", []int{1, 1, 1, 3, 3}, 3},
+		{"DAT 0\r\n\r\nDAT 1", []int{1, 1, 1, 3, 3}, 3},
+		{"DAT 0\r\n\n\rDAT 1", []int{1, 1, 1, 3, 3}, 3},
+		{"DAT 0\n\r\nDAT 1", []int{1, 1, 1, 3, 3}, 3},
+		{"; one\r;two\r\n\r\n  ; three\n\rfoo ; four\r", []int{1, 1, 2, 2, 4, 4, 5, 5, 5}, 6},
+		{"\r\n\r  \n\rJMP 0 ;\r\n", []int{4, 4, 4, 4}, 5},
+	}
+
+	for i, test := range tests {
+		l := Lex("lexTest", test.in)
+		for j, line := range test.lines {
+			item := l.NextItem()
+			if item.Line != line {
+				t.Errorf("test %d, item %d: got %s %q on line %d, want line %d", i, j, item.Typ, item.Val, item.Line, line)
+			}
+			if item.Typ != ItemEOL && strings.ContainsAny(item.Val, "\r\n") {
+				t.Errorf("test %d, item %d: got %s %q holding an EOL", i, j, item.Typ, item.Val)
+			}
+		}
+		if item := l.NextItem(); item.Typ != ItemEOF || item.Line != test.last {
+			t.Errorf("test %d: got %s on line %d, want EOF on line %d", i, item.Typ, item.Line, test.last)
+		}
+	}
+}
+
+func TestLexPositions(t *testing.T) {
+	in := "foo\n  mov.ab #1, <-2 ; µ\nDAT 0"
+	want := []struct {
+		val    string
+		offset int
+		line   int
+		column int
+	}{
+		{"foo", 0, 1, 1}, {"\n", 3, 1, 4},
+		{"mov", 6, 2, 3}, {"ab", 10, 2, 7}, {"#", 13, 2, 10}, {"1", 14, 2, 11}, {",", 15, 2, 12},
+		{"<", 17, 2, 14}, {"-", 18, 2, 15}, {"2", 19, 2, 16}, {" µ", 22, 2, 19}, {"\n", 25, 2, 21},
+		{"DAT", 26, 3, 1}, {"0", 30, 3, 5}, {"", 31, 3, 6},
+	}
+
+	l := Lex("lexTest", in)
+	for i, w := range want {
+		item := l.NextItem()
+		want := Pos{File: "lexTest", Offset: w.offset, Line: w.line, Column: w.column}
+		if item.Val != w.val || item.Pos != want {
+			t.Errorf("item %d: got %q at %+v; want %q at %+v", i, item.Val, item.Pos, w.val, want)
+		}
+	}
+}
+
+func TestSpan(t *testing.T) {
+	l := Lex("lexTest", "foo\n  MOV 0")
+	foo, eol, mov := l.NextItem(), l.NextItem(), l.NextItem()
+
+	tests := []struct {
+		span Span
+		want string
+	}{
+		{foo.Span(), "lexTest:1:1-4"},
+		{eol.Span(), "lexTest:1:4-2:1"},
+		{foo.Span().Join(mov.Span()), "lexTest:1:1-2:6"},
+		{mov.Span().Join(foo.Span()), "lexTest:1:1-2:6"},
+		{Span{}.Join(mov.Span()), "lexTest:2:3-6"},
+		{Span{}, "-"},
+	}
+	for i, test := range tests {
+		if got := test.span.String(); got != test.want {
+			t.Errorf("test %d: got %q, want %q", i, got, test.want)
+		}
+	}
+}
+
+// TestLexReader checks that lexing from a reader yields the very same
+// items as lexing a string, even when reads split runes in half.
+func TestLexReader(t *testing.T) {
+	paths, err := filepath.Glob("testdata/corpus/*.rc")
+	if err != nil {
+		t.Fatalf("error listing files: %v", err)
+	}
+	ins := []string{"", "DAT 0", "; ¿qué? ñandú\nMOV.I   0,  1 ; →\n", "JMP.Z 0\nSPL 0 & 1\nDAT 0\n", "DAT 0\r"}
+	for _, path := range append(paths, "testdata/dwarf.rc", "testdata/mixed.rc") {
+		in, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("error reading %q: %v", path, err)
+		}
+		ins = append(ins, string(in))
+	}
+
+	readers := map[string]func(string) io.Reader{
+		"whole":  func(in string) io.Reader { return strings.NewReader(in) },
+		"bytes":  func(in string) io.Reader { return iotest.OneByteReader(strings.NewReader(in)) },
+		"halves": func(in string) io.Reader { return iotest.HalfReader(strings.NewReader(in)) },
+		"eof":    func(in string) io.Reader { return iotest.DataErrReader(strings.NewReader(in)) },
+	}
+	for i, in := range ins {
+		for name, reader := range readers {
+			want, l := Lex("lexTest", in), LexReader("lexTest", reader(in))
+			for j := 0; ; j++ {
+				w, got := want.NextItem(), l.NextItem()
+				if got != w {
+					t.Errorf("input %d, %s reader, item %d: got %s %q at %v, want %s %q at %v", i, name, j, got.Typ, got.Val, got.Pos, w.Typ, w.Val, w.Pos)
+					break
+				}
+				if w.Typ == ItemEOF {
+					break
+				}
+			}
+			if err := l.Err(); err != nil {
+				t.Errorf("input %d, %s reader: unexpected error: %v", i, name, err)
+			}
+		}
+	}
+}
+
+func TestLexReaderError(t *testing.T) {
+	errBoom := errors.New("boom")
+	l := LexReader("lexTest", io.MultiReader(strings.NewReader("DAT 0\nMO"), iotest.ErrReader(errBoom)))
+
+	want := []item{{ItemOpcode, "DAT"}, {ItemNumber, "0"}, {ItemEOL, "\n"}, {ItemLabel, "MO"}, {ItemEOF, ""}}
+	for i, w := range want {
+		if got := l.NextItem(); got.Typ != w.Typ || got.Val != w.Val {
+			t.Errorf("item %d: got %s %q, want %s %q", i, got.Typ, got.Val, w.Typ, w.Val)
+		}
+	}
+	if err := l.Err(); !errors.Is(err, errBoom) {
+		t.Errorf("got error %v, want %v", err, errBoom)
+	}
+}
+
+// BenchmarkLex lexes every warrior in testdata, the corpus included. They
+// are read up front so that nothing but lexing gets timed.
+func BenchmarkLex(b *testing.B) {
+	paths, err := filepath.Glob("testdata/*.rc")
+	if err != nil {
+		b.Fatalf("error listing files: %v", err)
+	}
+	corpus, err := filepath.Glob("testdata/corpus/*.rc")
+	if err != nil {
+		b.Fatalf("error listing files: %v", err)
+	}
+
+	srcs := []string{}
+	size := 0
+	for _, path := range append(paths, corpus...) {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatalf("error reading %q: %v", path, err)
+		}
+		srcs = append(srcs, string(src))
+		size += len(src)
+	}
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, src := range srcs {
+			l := Lex("lexBench", src)
+			for l.NextItem().Typ != ItemEOF {
+			}
+		}
+	}
+}
+
+// BenchmarkLexReader lexes archives made up of more and more copies of the
+// warriors in testdata. Lexing takes linear time, so the throughput should
+// hold no matter the size.
+func BenchmarkLexReader(b *testing.B) {
+	paths, err := filepath.Glob("testdata/corpus/*.rc")
+	if err != nil {
+		b.Fatalf("error listing files: %v", err)
+	}
+
+	var corpus strings.Builder
+	for _, path := range append(paths, "testdata/dwarf.rc") {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatalf("error reading %q: %v", path, err)
+		}
+		corpus.Write(src)
+	}
+
+	for _, copies := range []int{1, 100, 1000} {
+		archive := strings.Repeat(corpus.String(), copies)
+		b.Run(fmt.Sprintf("%dKiB", len(archive)/1024), func(b *testing.B) {
+			b.SetBytes(int64(len(archive)))
+			b.ReportAllocs()
+			for b.Loop() {
+				l := LexReader("lexBench", strings.NewReader(archive))
+				for l.NextItem().Typ != ItemEOF {
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Pos is a position in the source of a warrior.
+type Pos struct {
+	File   string // the name of the input
+	Offset int    // byte offset, beginning at 0
+	Line   int    // line number, beginning at 1
+	Column int    // column number in runes, beginning at 1
+}
+
+// IsValid reports whether p points somewhere. The zero Pos doesn't.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns p in the usual file:line:column form. Any missing part
+// is simply left out.
+func (p Pos) String() string {
+	s := p.File
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// advance returns the position right after text, which begins at p. Every
+// kind of EOL counts as a single line break, which is why text mustn't end
+// halfway through one.
+func (p Pos) advance(text string) Pos {
+	p.Offset += len(text)
+	for {
+		i := strings.IndexAny(text, "\n\r")
+		if i == -1 {
+			break
+		}
+		if i+1 < len(text) && isEOL(rune(text[i+1])) && text[i+1] != text[i] {
+			i++
+		}
+		p.Line++
+		p.Column = 1
+		text = text[i+1:]
+	}
+	p.Column += utf8.RuneCountInString(text)
+	return p
+}
+
+// Span is the stretch of source between Start and End, which is the
+// position right after the last character.
+type Span struct {
+	Start, End Pos
+}
+
+// IsValid reports whether s covers any source at all.
+func (s Span) IsValid() bool {
+	return s.Start.IsValid()
+}
+
+// String returns the start of s in file:line:column form followed by the
+// line and column it ends at.
+func (s Span) String() string {
+	if !s.IsValid() {
+		return s.Start.String()
+	}
+	if s.End.Line == s.Start.Line {
+		return fmt.Sprintf("%s-%d", s.Start, s.End.Column)
+	}
+	return fmt.Sprintf("%s-%d:%d", s.Start, s.End.Line, s.End.Column)
+}
+
+// Join returns the smallest span covering both s and t. Invalid spans
+// are ignored.
+func (s Span) Join(t Span) Span {
+	switch {
+	case !s.IsValid():
+		return t
+	case !t.IsValid():
+		return s
+	}
+	if t.Start.Offset < s.Start.Offset {
+		s.Start = t.Start
+	}
+	if t.End.Offset > s.End.Offset {
+		s.End = t.End
+	}
+	return s
+}
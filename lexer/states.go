@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"context"
 	"log/slog"
 	"strings"
 )
@@ -8,15 +9,32 @@ import (
 // stateFn represents the state of the scanner as a function that returns the next state.
 type stateFn func(*Lexer) stateFn
 
+// debugging reports whether debug logs are on. States are entered for
+// every item, so they check it before building the arguments to log:
+// boxing them alone would cost several allocations per item.
+func debugging() bool {
+	return slog.Default().Enabled(context.Background(), slog.LevelDebug)
+}
+
+// trace logs that the lexer is entering the given state.
+func (l *Lexer) trace(state string) {
+	if debugging() {
+		slog.Debug("entering "+state, "start", l.start, "pos", l.pos, "c", string(l.peek()))
+	}
+}
+
 const (
 	commentDelim = ';'
 )
 
 func lexLine(l *Lexer) stateFn {
-	slog.Debug("entering lexLine", "start", l.start, "pos", l.pos, "c", string(l.peek()))
+	l.trace("lexLine")
 
-	// gobble up leading whitespace
-	for isSpace(l.next()) {
+	// gobble up leading whitespace, blank lines included
+	for r := l.next(); isSpace(r); r = l.next() {
+		if isEOL(r) {
+			l.acceptEOL(r)
+		}
 		l.ignore()
 	}
 	l.backup()
@@ -39,7 +57,7 @@ func lexLine(l *Lexer) stateFn {
 }
 
 func lexInstruction(l *Lexer) stateFn {
-	slog.Debug("entering lexInstruction", "start", l.start, "pos", l.pos, "c", string(l.peek()))
+	l.trace("lexInstruction")
 	for {
 		switch r := l.next(); {
 		case '0' <= r && r <= '9':
@@ -48,18 +66,28 @@ func lexInstruction(l *Lexer) stateFn {
 		case isAlphaNumeric(r):
 			l.backup()
 			return lexIdentifier
-		case r == '.': // instruction mode
+		case r == '.': // instruction modifier
 			l.ignore()
-			return lexIdentifier
-		case strings.Index("#$@<>", string(r)) != -1: // addressing mode
+			return lexModifier
+		// Each of these emits a single item and returns so that NextItem can
+		// drain the ring before the next one: it only holds ringSize items
+		// and push panics when it's full. Think of "#-(((((".
+		case strings.ContainsRune("#$@<>{}", r): // addressing mode
 			l.emit(key[string(r)])
-			continue
-		case strings.Index("+-*/%()", string(r)) != -1: // operand
+			return lexInstruction
+		case r == '*' && l.atOperand(): // A-field indirect rather than a product
+			l.emit(ItemAddressingMode)
+			return lexInstruction
+		case strings.ContainsRune("+-*/%()", r): // operand
 			l.emit(key[string(r)])
-			continue
+			return lexInstruction
+		case r == ',': // field separator
+			l.emit(ItemComma)
+			return lexInstruction
 		case r == commentDelim: // gobble trailing comments
 			return lexComment
 		case isEOL(r):
+			l.acceptEOL(r)
 			l.emit(ItemEOL)
 			return lexLine
 		case r == eof:
@@ -67,36 +95,80 @@ func lexInstruction(l *Lexer) stateFn {
 			return lexLine
 		case isSpace(r): // ignore whitespace
 			l.ignore()
+		default:
+			return l.errorf("unexpected character %q", r)
 		}
 	}
 }
 
-// lexIdentifier scans an alphanumeric or field.
+// lexSkipLine drops whatever is left of the line after an error, up to but
+// not including its EOL.
+func lexSkipLine(l *Lexer) stateFn {
+	for r := l.peek(); !isEOL(r) && r != eof; r = l.peek() {
+		l.next()
+	}
+	l.ignore()
+	return lexInstruction
+}
+
+// lexIdentifier scans an alphanumeric: either an opcode or a label. Just
+// like in pMARS, opcodes are case insensitive.
 func lexIdentifier(l *Lexer) stateFn {
-	slog.Debug("entering lexIdentifier", "start", l.start, "pos", l.pos, "c", string(l.peek()))
-Loop:
-	for {
-		switch r := l.next(); {
-		case isAlphaNumeric(r):
-			// absorb.
-		default:
-			l.backup()
-			word := l.input[l.start:l.pos]
-			switch {
-			case key[word] > 0:
-				l.emit(key[word])
-			default:
-				l.emit(ItemLabel)
-			}
-			break Loop
-		}
+	l.trace("lexIdentifier")
+	word := l.scanWord()
+	if keyword(word) == ItemOpcode {
+		l.emit(ItemOpcode)
+	} else {
+		l.emit(ItemLabel)
 	}
 	return lexInstruction
 }
 
+// lexModifier scans the modifier following an opcode's dot. Modifiers are
+// only recognised there, so labels such as 'a' or 'x' are fine elsewhere.
+func lexModifier(l *Lexer) stateFn {
+	l.trace("lexModifier")
+	word := l.scanWord()
+	if keyword(word) != ItemOpcodeModifier {
+		return l.errorf("bad modifier: %q", word)
+	}
+	l.emit(ItemOpcodeModifier)
+	return lexInstruction
+}
+
+// keyword returns the type of word if it's an opcode or a modifier, no
+// matter its case, and ItemError otherwise. Keywords are short enough to
+// be upper-cased on the stack, which spares an allocation per label.
+func keyword(word string) ItemType {
+	var buf [3]byte // as long as the longest keyword
+	if len(word) > len(buf) {
+		return ItemError
+	}
+	for i := range len(word) {
+		c := word[i]
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		buf[i] = c
+	}
+	switch t := key[string(buf[:len(word)])]; t {
+	case ItemOpcode, ItemOpcodeModifier:
+		return t
+	}
+	return ItemError
+}
+
+// scanWord consumes a run of alphanumerics and returns it.
+func (l *Lexer) scanWord() string {
+	for isAlphaNumeric(l.next()) {
+	}
+	l.backup()
+	return l.input[l.start:l.pos]
+}
+
 // lexNumber scans a decimal number This isn't a perfect number scanner!
 func lexNumber(l *Lexer) stateFn {
-	slog.Debug("entering lexNumber", "start", l.start, "pos", l.pos, "c", string(l.peek()))
+	l.trace("lexNumber")
 	if !l.scanNumber() {
 		return l.errorf("bad number syntax: %q", l.input[l.start:l.pos])
 	}
@@ -121,7 +193,7 @@ func (l *Lexer) scanNumber() bool {
 }
 
 func lexComment(l *Lexer) stateFn {
-	slog.Debug("entering lexLineComment", "start", l.start, "pos", l.pos, "c", string(l.peek()))
+	l.trace("lexLineComment")
 
 	// drop the comment delimiter
 	l.ignore()
@@ -129,16 +201,19 @@ func lexComment(l *Lexer) stateFn {
 	// read until the end of line
 	for {
 		n := l.next()
-		slog.Debug("lexComment", "n", string(n), "l.start", l.start, "l.pos", l.pos, "l.width", l.width)
+		if debugging() {
+			slog.Debug("lexComment", "n", string(n), "l.start", l.start, "l.pos", l.pos, "l.width", l.width)
+		}
 		if isEOL(n) {
 			l.backup() // careful, l.peek changes l.width and doesn't rever it!
 			l.emit(ItemComment)
-			l.next()
+			l.acceptEOL(l.next())
 			l.emit(ItemEOL)
 			return lexLine
 		}
 		if n == eof {
 			l.backup()
+			l.emit(ItemComment)
 			return lexLine
 		}
 	}
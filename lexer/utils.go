@@ -16,8 +16,8 @@ func isAlphaNumeric(r rune) bool {
 	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
 }
 
-// isEOL reports whether r is an end of line (EOL)
-// TODO: check for "\r\n"
+// isEOL reports whether r begins an end of line (EOL). That's either an
+// LF or a CR, which can be followed by the other one: see acceptEOL.
 func isEOL(r rune) bool {
-	return r == '\n'
+	return r == '\n' || r == '\r'
 }
@@ -0,0 +1,88 @@
+package mars
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrBadConfig = errors.New("bad configuration")
+
+// Config holds the parameters of a MARS. The defaults are the
+// ones pMARS uses, which are in turn those of most KotH hills.
+type Config struct {
+	CoreSize     int // number of instructions in the core
+	MaxCycles    int // cycles before a battle is declared a tie
+	MaxProcesses int // maximum number of processes per warrior
+	MaxLength    int // maximum number of instructions in a warrior
+	PSpaceSize   int // cells in each warrior's P-space: 0 means CoreSize/16
+	ReadLimit    int // how far away operands can read: must divide CoreSize, 0 means CoreSize
+	WriteLimit   int // how far away operands can write: must divide CoreSize, 0 means CoreSize
+	MinDistance  int // minimum distance between warriors: 0 means MaxLength
+}
+
+// DefaultConfig returns the configuration used by the '94 draft hill.
+func DefaultConfig() Config {
+	return Config{
+		CoreSize:     8000,
+		MaxCycles:    80000,
+		MaxProcesses: 8000,
+		MaxLength:    100,
+	}
+}
+
+func (c Config) validate() error {
+	switch {
+	case c.CoreSize < 1:
+		return fmt.Errorf("%w: core size must be positive, got %d", ErrBadConfig, c.CoreSize)
+	case c.MaxCycles < 1:
+		return fmt.Errorf("%w: max cycles must be positive, got %d", ErrBadConfig, c.MaxCycles)
+	case c.MaxProcesses < 1:
+		return fmt.Errorf("%w: max processes must be positive, got %d", ErrBadConfig, c.MaxProcesses)
+	case c.MaxLength < 1 || c.MaxLength > c.CoreSize:
+		return fmt.Errorf("%w: max length must be within [1, %d], got %d", ErrBadConfig, c.CoreSize, c.MaxLength)
+	case c.PSpaceSize < 0:
+		return fmt.Errorf("%w: P-space size can't be negative, got %d", ErrBadConfig, c.PSpaceSize)
+	case c.ReadLimit < 0 || c.ReadLimit > c.CoreSize:
+		return fmt.Errorf("%w: read limit must be within [0, %d], got %d", ErrBadConfig, c.CoreSize, c.ReadLimit)
+	case c.ReadLimit != 0 && c.CoreSize%c.ReadLimit != 0:
+		return fmt.Errorf("%w: read limit must divide the core size (%d), got %d", ErrBadConfig, c.CoreSize, c.ReadLimit)
+	case c.WriteLimit < 0 || c.WriteLimit > c.CoreSize:
+		return fmt.Errorf("%w: write limit must be within [0, %d], got %d", ErrBadConfig, c.CoreSize, c.WriteLimit)
+	case c.WriteLimit != 0 && c.CoreSize%c.WriteLimit != 0:
+		return fmt.Errorf("%w: write limit must divide the core size (%d), got %d", ErrBadConfig, c.CoreSize, c.WriteLimit)
+	case c.MinDistance != 0 && (c.MinDistance < c.MaxLength || c.MinDistance > c.CoreSize):
+		return fmt.Errorf("%w: min distance must be within [%d, %d], got %d", ErrBadConfig, c.MaxLength, c.CoreSize, c.MinDistance)
+	}
+	return nil
+}
+
+// limits returns the actual read and write limits. Leaving them
+// out means warriors can reach the whole core.
+func (c Config) limits() (read, write int) {
+	read, write = c.ReadLimit, c.WriteLimit
+	if read == 0 {
+		read = c.CoreSize
+	}
+	if write == 0 {
+		write = c.CoreSize
+	}
+	return read, write
+}
+
+// pSpaceSize returns the actual size of P-space, which defaults to a
+// sixteenth of the core just like in pMARS.
+func (c Config) pSpaceSize() int {
+	if c.PSpaceSize > 0 {
+		return c.PSpaceSize
+	}
+	return max(c.CoreSize/16, 1)
+}
+
+// minDistance returns the actual minimum distance between warriors, which
+// is never less than their maximum length so that they can't overlap.
+func (c Config) minDistance() int {
+	if c.MinDistance > 0 {
+		return c.MinDistance
+	}
+	return c.MaxLength
+}
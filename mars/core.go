@@ -0,0 +1,103 @@
+package mars
+
+import (
+	"fmt"
+
+	"github.com/pcolladosoto/corewarg/assembler"
+	"github.com/pcolladosoto/corewarg/parser"
+)
+
+// Instruction is the content of a core cell. Unlike assembled
+// instructions, fields are always within [0, core size).
+type Instruction struct {
+	Opcode   parser.Opcode
+	Modifier parser.OpcodeModifier
+	AMode    parser.AddressingMode
+	AField   int
+	BMode    parser.AddressingMode
+	BField   int
+}
+
+func (i Instruction) String() string {
+	return fmt.Sprintf("%s.%s %s%d, %s%d", i.Opcode, i.Modifier, i.AMode, i.AField, i.BMode, i.BField)
+}
+
+// Core is the circular memory warriors fight in. Every
+// address is taken modulo its size.
+type Core struct {
+	cells      []Instruction
+	readLimit  int // how far operands can read from the executing instruction
+	writeLimit int // how far operands can write from the executing instruction
+}
+
+// NewCore returns a core of the given size. Just like the standard says,
+// every cell starts out as DAT.F $0, $0. Reads and writes aren't limited.
+func NewCore(size int) *Core {
+	c := &Core{cells: make([]Instruction, size), readLimit: size, writeLimit: size}
+	for i := range c.cells {
+		c.cells[i] = Instruction{Opcode: parser.DAT, Modifier: parser.F, AMode: parser.Dollar, BMode: parser.Dollar}
+	}
+	return c
+}
+
+// Size returns the number of cells in the core.
+func (c *Core) Size() int {
+	return len(c.cells)
+}
+
+// Fold maps any address, even a negative one, into [0, c.Size()).
+func (c *Core) Fold(addr int) int {
+	return fold(addr, len(c.cells))
+}
+
+// limit folds a pointer relative to the executing instruction so that it
+// stays within lim/2 of it either way. This is the standard's Fold: when lim
+// is the size of the core it boils down to Fold. lim must divide the size of
+// the core, as pointers are kept modulo it and would shift otherwise.
+func (c *Core) limit(ptr, lim int) int {
+	ptr = fold(ptr, lim)
+	if ptr > lim/2 {
+		ptr += len(c.cells) - lim
+	}
+	return ptr
+}
+
+// fold maps addr into [0, size).
+func fold(addr, size int) int {
+	addr %= size
+	if addr < 0 {
+		addr += size
+	}
+	return addr
+}
+
+// Get returns the instruction at addr.
+func (c *Core) Get(addr int) Instruction {
+	return c.cells[c.Fold(addr)]
+}
+
+// Set stores ins at addr, folding its fields into the core.
+func (c *Core) Set(addr int, ins Instruction) {
+	ins.AField, ins.BField = c.Fold(ins.AField), c.Fold(ins.BField)
+	c.cells[c.Fold(addr)] = ins
+}
+
+// cell returns a pointer to the cell at addr so that fields can be
+// updated in place. The caller is in charge of folding them.
+func (c *Core) cell(addr int) *Instruction {
+	return &c.cells[c.Fold(addr)]
+}
+
+// load copies code into the core beginning at addr.
+func (c *Core) load(addr int, code []assembler.Instruction) {
+	for i, ins := range code {
+		c.Set(addr+i, Instruction{
+			Opcode:   ins.Opcode,
+			Modifier: ins.Modifier,
+			AMode:    ins.AMode,
+			AField:   ins.AField,
+			BMode:    ins.BMode,
+			BField:   ins.BField,
+		})
+	}
+}
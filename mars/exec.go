@@ -0,0 +1,236 @@
+package mars
+
+import "github.com/pcolladosoto/corewarg/parser"
+
+// execute runs the instruction at pc on behalf of w, mimicking the ICWS'94
+// reference emulator. The process is queued back wherever execution should
+// continue unless it dies along the way.
+func (m *MARS) execute(w *Warrior, pc int) {
+	r := m.core.Evaluate(pc)
+	ira, irb := r.IRA, r.IRB
+	target := m.core.cell(pc + r.WPB)
+	next, jump := pc+1, pc+r.RPA
+
+	switch r.IR.Opcode {
+	case parser.DAT:
+		return // the process dies
+
+	case parser.MOV:
+		switch r.IR.Modifier {
+		case parser.A:
+			target.AField = ira.AField
+		case parser.B:
+			target.BField = ira.BField
+		case parser.AB:
+			target.BField = ira.AField
+		case parser.BA:
+			target.AField = ira.BField
+		case parser.F:
+			target.AField, target.BField = ira.AField, ira.BField
+		case parser.X:
+			target.AField, target.BField = ira.BField, ira.AField
+		case parser.I:
+			*target = ira
+		}
+
+	case parser.ADD, parser.SUB, parser.MUL:
+		op := m.arithmetic(r.IR.Opcode)
+		m.apply(r.IR.Modifier, target, ira, irb, func(a, b int) (int, bool) { return op(a, b), true })
+
+	case parser.DIV, parser.MOD:
+		// Components with a non-zero divisor are written no matter
+		// what, but any division by zero kills the process.
+		op := m.arithmetic(r.IR.Opcode)
+		ok := m.apply(r.IR.Modifier, target, ira, irb, func(a, b int) (int, bool) {
+			if a == 0 {
+				return 0, false
+			}
+			return op(a, b), true
+		})
+		if !ok {
+			return
+		}
+
+	case parser.JMP:
+		next = jump
+
+	case parser.JMZ:
+		if m.test(r.IR.Modifier, irb, func(v int) bool { return v == 0 }, true) {
+			next = jump
+		}
+
+	case parser.JMN:
+		if m.test(r.IR.Modifier, irb, func(v int) bool { return v != 0 }, false) {
+			next = jump
+		}
+
+	case parser.DJN:
+		dec := func(v int) int { return m.core.Fold(v - 1) }
+		switch r.IR.Modifier {
+		case parser.A, parser.BA:
+			target.AField, irb.AField = dec(target.AField), dec(irb.AField)
+		case parser.B, parser.AB:
+			target.BField, irb.BField = dec(target.BField), dec(irb.BField)
+		case parser.F, parser.X, parser.I:
+			target.AField, irb.AField = dec(target.AField), dec(irb.AField)
+			target.BField, irb.BField = dec(target.BField), dec(irb.BField)
+		}
+		if m.test(r.IR.Modifier, irb, func(v int) bool { return v != 0 }, false) {
+			next = jump
+		}
+
+	case parser.CMP, parser.SEQ:
+		if m.compare(r.IR.Modifier, ira, irb, func(a, b int) bool { return a == b }) {
+			next = pc + 2
+		}
+
+	case parser.SNE:
+		if !m.compare(r.IR.Modifier, ira, irb, func(a, b int) bool { return a == b }) {
+			next = pc + 2
+		}
+
+	case parser.SLT:
+		// opcodes and addressing modes can't be ordered: .I behaves as .F
+		mod := r.IR.Modifier
+		if mod == parser.I {
+			mod = parser.F
+		}
+		if m.compare(mod, ira, irb, func(a, b int) bool { return a < b }) {
+			next = pc + 2
+		}
+
+	case parser.SPL:
+		w.queue.push(m.core.Fold(next))
+		next = jump
+
+	case parser.NOP:
+
+	// P-space only knows about a single field, so .F, .X
+	// and .I behave just like .B for LDP and STP.
+	case parser.LDP:
+		switch r.IR.Modifier {
+		case parser.A:
+			target.AField = m.load(w, ira.AField)
+		case parser.AB:
+			target.BField = m.load(w, ira.AField)
+		case parser.BA:
+			target.AField = m.load(w, ira.BField)
+		default:
+			target.BField = m.load(w, ira.BField)
+		}
+
+	case parser.STP:
+		switch r.IR.Modifier {
+		case parser.A:
+			m.store(w, irb.AField, ira.AField)
+		case parser.AB:
+			m.store(w, irb.BField, ira.AField)
+		case parser.BA:
+			m.store(w, irb.AField, ira.BField)
+		default:
+			m.store(w, irb.BField, ira.BField)
+		}
+	}
+
+	w.queue.push(m.core.Fold(next))
+}
+
+// arithmetic returns the operation carried out by an arithmetic opcode.
+// The B operand comes first as it's the one being modified, i.e. SUB
+// subtracts A from B. Results are folded back into the core.
+func (m *MARS) arithmetic(op parser.Opcode) func(a, b int) int {
+	switch op {
+	case parser.ADD:
+		return func(a, b int) int { return m.core.Fold(b + a) }
+	case parser.SUB:
+		return func(a, b int) int { return m.core.Fold(b - a) }
+	case parser.MUL:
+		return func(a, b int) int { return m.core.Fold(b * a) }
+	case parser.DIV:
+		return func(a, b int) int { return b / a }
+	}
+	return func(a, b int) int { return b % a }
+}
+
+// apply combines the fields of ira and irb with op as dictated by the modifier
+// and writes the results to target. Results op deems wrong aren't written, in
+// which case apply reports false once every field has been dealt with.
+func (m *MARS) apply(mod parser.OpcodeModifier, target *Instruction, ira, irb Instruction, op func(a, b int) (int, bool)) bool {
+	ok := true
+	write := func(field *int, a, b int) {
+		v, valid := op(a, b)
+		if !valid {
+			ok = false
+			return
+		}
+		*field = v
+	}
+
+	switch mod {
+	case parser.A:
+		write(&target.AField, ira.AField, irb.AField)
+	case parser.B:
+		write(&target.BField, ira.BField, irb.BField)
+	case parser.AB:
+		write(&target.BField, ira.AField, irb.BField)
+	case parser.BA:
+		write(&target.AField, ira.BField, irb.AField)
+	case parser.F, parser.I:
+		write(&target.AField, ira.AField, irb.AField)
+		write(&target.BField, ira.BField, irb.BField)
+	case parser.X:
+		write(&target.AField, ira.BField, irb.AField)
+		write(&target.BField, ira.AField, irb.BField)
+	}
+	return ok
+}
+
+// test checks the fields of irb selected by the modifier with cond. When both
+// fields are selected they must both pass the check if all is true. Otherwise,
+// it's enough for either of them to pass it.
+func (m *MARS) test(mod parser.OpcodeModifier, irb Instruction, cond func(v int) bool, all bool) bool {
+	switch mod {
+	case parser.A, parser.BA:
+		return cond(irb.AField)
+	case parser.B, parser.AB:
+		return cond(irb.BField)
+	}
+	if all {
+		return cond(irb.AField) && cond(irb.BField)
+	}
+	return cond(irb.AField) || cond(irb.BField)
+}
+
+// compare checks the fields of ira against those of irb as selected by the
+// modifier. Every pair must pass the check. On top of that, .I also wants
+// both opcodes, modifiers and addressing modes to match.
+func (m *MARS) compare(mod parser.OpcodeModifier, ira, irb Instruction, cond func(a, b int) bool) bool {
+	switch mod {
+	case parser.A:
+		return cond(ira.AField, irb.AField)
+	case parser.B:
+		return cond(ira.BField, irb.BField)
+	case parser.AB:
+		return cond(ira.AField, irb.BField)
+	case parser.BA:
+		return cond(ira.BField, irb.AField)
+	case parser.F:
+		return cond(ira.AField, irb.AField) && cond(ira.BField, irb.BField)
+	case parser.X:
+		return cond(ira.AField, irb.BField) && cond(ira.BField, irb.AField)
+	}
+	return ira.Opcode == irb.Opcode && ira.Modifier == irb.Modifier &&
+		ira.AMode == irb.AMode && ira.BMode == irb.BMode &&
+		cond(ira.AField, irb.AField) && cond(ira.BField, irb.BField)
+}
+
+// load returns the value in the P-space cell of w at addr folded into
+// the core: the result of the first round, for one, is negative.
+func (m *MARS) load(w *Warrior, addr int) int {
+	return m.core.Fold(w.pspace.Get(addr))
+}
+
+// store writes v to the P-space cell of w at addr.
+func (m *MARS) store(w *Warrior, addr, v int) {
+	w.pspace.Set(addr, v)
+}
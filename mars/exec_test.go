@@ -0,0 +1,316 @@
+package mars
+
+import (
+	"slices"
+	"testing"
+)
+
+// execTests run the first instruction of code, which is loaded at address 0
+// of a core with 80 cells, and check the core and the process queue after it.
+type execTests []struct {
+	code string
+	core map[int]string // expected content of these addresses
+	pcs  []int          // expected process queue
+}
+
+func runExecTests(t *testing.T, cfg Config, ts execTests) {
+	t.Helper()
+	for i, test := range ts {
+		m := newMARS(t, cfg, map[int]string{0: test.code})
+		m.Step()
+
+		for addr, want := range test.core {
+			if got := m.Core().Get(addr).String(); got != want {
+				t.Errorf("test %d (%q): cell %d: got %q, want %q", i, test.code, addr, got, want)
+			}
+		}
+		if got := m.Warriors()[0].Processes(); !slices.Equal(got, test.pcs) {
+			t.Errorf("test %d (%q): got processes %v, want %v", i, test.code, got, test.pcs)
+		}
+	}
+}
+
+func TestExecDAT(t *testing.T) {
+	runExecTests(t, smallConfig(), execTests{
+		{"DAT.F #1, #2\n", map[int]string{0: "DAT.F #1, #2"}, nil},
+		{"DAT.A $1, $2\n", map[int]string{1: "DAT.F $0, $0"}, nil},
+	})
+}
+
+func TestExecMOV(t *testing.T) {
+	src := "\nDAT.AB #3, @4\nDAT.F $5, $6\n"
+	runExecTests(t, smallConfig(), execTests{
+		{"MOV.A 1, 2" + src, map[int]string{2: "DAT.F $3, $6"}, []int{1}},
+		{"MOV.B 1, 2" + src, map[int]string{2: "DAT.F $5, $4"}, []int{1}},
+		{"MOV.AB 1, 2" + src, map[int]string{2: "DAT.F $5, $3"}, []int{1}},
+		{"MOV.BA 1, 2" + src, map[int]string{2: "DAT.F $4, $6"}, []int{1}},
+		{"MOV.F 1, 2" + src, map[int]string{2: "DAT.F $3, $4"}, []int{1}},
+		{"MOV.X 1, 2" + src, map[int]string{2: "DAT.F $4, $3"}, []int{1}},
+		{"MOV.I 1, 2" + src, map[int]string{2: "DAT.AB #3, @4"}, []int{1}},
+		{"MOV.AB #7, 2" + src, map[int]string{2: "DAT.F $5, $7"}, []int{1}},
+		{"MOV.I 1, @1\nDAT.F $0, $2\n", map[int]string{3: "DAT.F $0, $2"}, []int{1}},
+	})
+}
+
+func TestExecArithmetic(t *testing.T) {
+	src := "\nDAT.F $3, $7\nDAT.F $5, $6\n"
+	runExecTests(t, smallConfig(), execTests{
+		{"ADD.A 1, 2" + src, map[int]string{2: "DAT.F $8, $6"}, []int{1}},
+		{"ADD.B 1, 2" + src, map[int]string{2: "DAT.F $5, $13"}, []int{1}},
+		{"ADD.AB 1, 2" + src, map[int]string{2: "DAT.F $5, $9"}, []int{1}},
+		{"ADD.BA 1, 2" + src, map[int]string{2: "DAT.F $12, $6"}, []int{1}},
+		{"ADD.F 1, 2" + src, map[int]string{2: "DAT.F $8, $13"}, []int{1}},
+		{"ADD.X 1, 2" + src, map[int]string{2: "DAT.F $12, $9"}, []int{1}},
+		{"ADD.I 1, 2" + src, map[int]string{2: "DAT.F $8, $13"}, []int{1}},
+		{"ADD.AB #79, 2" + src, map[int]string{2: "DAT.F $5, $5"}, []int{1}},
+		{"ADD.AB #1, 0" + src, map[int]string{0: "ADD.AB #1, $1"}, []int{1}},
+
+		{"SUB.A 1, 2" + src, map[int]string{2: "DAT.F $2, $6"}, []int{1}},
+		{"SUB.B 1, 2" + src, map[int]string{2: "DAT.F $5, $79"}, []int{1}},
+		{"SUB.AB 1, 2" + src, map[int]string{2: "DAT.F $5, $3"}, []int{1}},
+		{"SUB.BA 1, 2" + src, map[int]string{2: "DAT.F $78, $6"}, []int{1}},
+		{"SUB.F 1, 2" + src, map[int]string{2: "DAT.F $2, $79"}, []int{1}},
+		{"SUB.X 1, 2" + src, map[int]string{2: "DAT.F $78, $3"}, []int{1}},
+		{"SUB.I 1, 2" + src, map[int]string{2: "DAT.F $2, $79"}, []int{1}},
+
+		{"MUL.A 1, 2" + src, map[int]string{2: "DAT.F $15, $6"}, []int{1}},
+		{"MUL.B 1, 2" + src, map[int]string{2: "DAT.F $5, $42"}, []int{1}},
+		{"MUL.AB 1, 2" + src, map[int]string{2: "DAT.F $5, $18"}, []int{1}},
+		{"MUL.BA 1, 2" + src, map[int]string{2: "DAT.F $35, $6"}, []int{1}},
+		{"MUL.F 1, 2" + src, map[int]string{2: "DAT.F $15, $42"}, []int{1}},
+		{"MUL.X 1, 2" + src, map[int]string{2: "DAT.F $35, $18"}, []int{1}},
+		{"MUL.I 1, 2" + src, map[int]string{2: "DAT.F $15, $42"}, []int{1}},
+		{"MUL.AB #20, 2" + src, map[int]string{2: "DAT.F $5, $40"}, []int{1}},
+	})
+}
+
+func TestExecDivision(t *testing.T) {
+	div := "\nDAT.F $2, $4\nDAT.F $9, $13\n"
+	mod := "\nDAT.F $2, $4\nDAT.F $11, $14\n"
+	runExecTests(t, smallConfig(), execTests{
+		{"DIV.A 1, 2" + div, map[int]string{2: "DAT.F $4, $13"}, []int{1}},
+		{"DIV.B 1, 2" + div, map[int]string{2: "DAT.F $9, $3"}, []int{1}},
+		{"DIV.AB 1, 2" + div, map[int]string{2: "DAT.F $9, $6"}, []int{1}},
+		{"DIV.BA 1, 2" + div, map[int]string{2: "DAT.F $2, $13"}, []int{1}},
+		{"DIV.F 1, 2" + div, map[int]string{2: "DAT.F $4, $3"}, []int{1}},
+		{"DIV.X 1, 2" + div, map[int]string{2: "DAT.F $2, $6"}, []int{1}},
+		{"DIV.I 1, 2" + div, map[int]string{2: "DAT.F $4, $3"}, []int{1}},
+
+		{"MOD.A 1, 2" + mod, map[int]string{2: "DAT.F $1, $14"}, []int{1}},
+		{"MOD.B 1, 2" + mod, map[int]string{2: "DAT.F $11, $2"}, []int{1}},
+		{"MOD.AB 1, 2" + mod, map[int]string{2: "DAT.F $11, $0"}, []int{1}},
+		{"MOD.BA 1, 2" + mod, map[int]string{2: "DAT.F $3, $14"}, []int{1}},
+		{"MOD.F 1, 2" + mod, map[int]string{2: "DAT.F $1, $2"}, []int{1}},
+		{"MOD.X 1, 2" + mod, map[int]string{2: "DAT.F $3, $0"}, []int{1}},
+		{"MOD.I 1, 2" + mod, map[int]string{2: "DAT.F $1, $2"}, []int{1}},
+	})
+}
+
+func TestExecDivisionByZero(t *testing.T) {
+	src := "\nDAT.F $0, $4\nDAT.F $9, $13\n"
+	runExecTests(t, smallConfig(), execTests{
+		// the process dies, but the components with a non-zero divisor are written
+		{"DIV.A 1, 2" + src, map[int]string{2: "DAT.F $9, $13"}, nil},
+		{"DIV.B 1, 2" + src, map[int]string{2: "DAT.F $9, $3"}, []int{1}},
+		{"DIV.AB 1, 2" + src, map[int]string{2: "DAT.F $9, $13"}, nil},
+		{"DIV.BA 1, 2" + src, map[int]string{2: "DAT.F $2, $13"}, []int{1}},
+		{"DIV.F 1, 2" + src, map[int]string{2: "DAT.F $9, $3"}, nil},
+		{"DIV.X 1, 2" + src, map[int]string{2: "DAT.F $2, $13"}, nil},
+		{"DIV.I 1, 2" + src, map[int]string{2: "DAT.F $9, $3"}, nil},
+
+		{"MOD.A 1, 2" + src, map[int]string{2: "DAT.F $9, $13"}, nil},
+		{"MOD.B 1, 2" + src, map[int]string{2: "DAT.F $9, $1"}, []int{1}},
+		{"MOD.F 1, 2" + src, map[int]string{2: "DAT.F $9, $1"}, nil},
+		{"MOD.X 1, 2" + src, map[int]string{2: "DAT.F $1, $13"}, nil},
+	})
+}
+
+func TestExecJumps(t *testing.T) {
+	runExecTests(t, smallConfig(), execTests{
+		{"JMP.A 2\n", nil, []int{2}},
+		{"JMP.B -1\n", nil, []int{79}},
+		{"JMP #5\n", nil, []int{0}},
+		{"JMP @1\nDAT 0, 3\n", nil, []int{4}},
+
+		{"JMZ.A 2, 1\nDAT.F $0, $5\n", nil, []int{2}},
+		{"JMZ.B 2, 1\nDAT.F $0, $5\n", nil, []int{1}},
+		{"JMZ.AB 2, 1\nDAT.F $0, $5\n", nil, []int{1}},
+		{"JMZ.BA 2, 1\nDAT.F $0, $5\n", nil, []int{2}},
+		{"JMZ.F 2, 1\nDAT.F $0, $5\n", nil, []int{1}},
+		{"JMZ.F 2, 1\nDAT.F $0, $0\n", nil, []int{2}},
+		{"JMZ.X 2, 1\nDAT.F $0, $5\n", nil, []int{1}},
+		{"JMZ.I 2, 1\nDAT.F $0, $0\n", nil, []int{2}},
+
+		{"JMN.A 2, 1\nDAT.F $0, $5\n", nil, []int{1}},
+		{"JMN.B 2, 1\nDAT.F $0, $5\n", nil, []int{2}},
+		{"JMN.AB 2, 1\nDAT.F $0, $5\n", nil, []int{2}},
+		{"JMN.BA 2, 1\nDAT.F $0, $5\n", nil, []int{1}},
+		{"JMN.F 2, 1\nDAT.F $0, $5\n", nil, []int{2}},
+		{"JMN.F 2, 1\nDAT.F $0, $0\n", nil, []int{1}},
+		{"JMN.X 2, 1\nDAT.F $5, $0\n", nil, []int{2}},
+		{"JMN.I 2, 1\nDAT.F $0, $0\n", nil, []int{1}},
+	})
+}
+
+func TestExecDJN(t *testing.T) {
+	runExecTests(t, smallConfig(), execTests{
+		{"DJN.A 2, 1\nDAT.F $1, $5\n", map[int]string{1: "DAT.F $0, $5"}, []int{1}},
+		{"DJN.A 2, 1\nDAT.F $0, $5\n", map[int]string{1: "DAT.F $79, $5"}, []int{2}},
+		{"DJN.B 2, 1\nDAT.F $1, $5\n", map[int]string{1: "DAT.F $1, $4"}, []int{2}},
+		{"DJN.AB 2, 1\nDAT.F $1, $1\n", map[int]string{1: "DAT.F $1, $0"}, []int{1}},
+		{"DJN.BA 2, 1\nDAT.F $3, $1\n", map[int]string{1: "DAT.F $2, $1"}, []int{2}},
+		{"DJN.F 2, 1\nDAT.F $1, $5\n", map[int]string{1: "DAT.F $0, $4"}, []int{2}},
+		{"DJN.F 2, 1\nDAT.F $1, $1\n", map[int]string{1: "DAT.F $0, $0"}, []int{1}},
+		{"DJN.X 2, 1\nDAT.F $2, $1\n", map[int]string{1: "DAT.F $1, $0"}, []int{2}},
+		{"DJN.I 2, 1\nDAT.F $1, $1\n", map[int]string{1: "DAT.F $0, $0"}, []int{1}},
+		{"DJN.B 0, #1\n", map[int]string{0: "DJN.B $0, #0"}, []int{1}},
+	})
+}
+
+func TestExecCMP(t *testing.T) {
+	eq := "\nDAT.F $3, $4\nDAT.F $3, $5\n"
+	swapped := "\nDAT.F $3, $4\nDAT.F $4, $3\n"
+	runExecTests(t, smallConfig(), execTests{
+		{"CMP.A 1, 2" + eq, nil, []int{2}},
+		{"CMP.B 1, 2" + eq, nil, []int{1}},
+		{"CMP.F 1, 2" + eq, nil, []int{1}},
+		{"CMP.AB 1, 2" + eq, nil, []int{1}},
+		{"CMP.AB 1, 2" + swapped, nil, []int{2}},
+		{"CMP.BA 1, 2" + swapped, nil, []int{2}},
+		{"CMP.X 1, 2" + swapped, nil, []int{2}},
+		{"CMP.F 1, 2" + swapped, nil, []int{1}},
+		{"CMP.I 1, 2\nDAT.F $3, $4\nDAT.F $3, $4\n", nil, []int{2}},
+		{"CMP.I 1, 2\nDAT.F $3, $4\nDAT.AB $3, $4\n", nil, []int{1}},
+		{"CMP.I 1, 2\nDAT.F $3, $4\nDAT.F #3, $4\n", nil, []int{1}},
+		{"CMP.I 1, 2\nDAT.F $3, $4\nMOV.F $3, $4\n", nil, []int{1}},
+		{"CMP.F 1, 2\nDAT.F $3, $4\nMOV.AB #3, @4\n", nil, []int{2}},
+	})
+}
+
+func TestExecSLT(t *testing.T) {
+	src := "\nDAT.F $3, $4\nDAT.F $5, $2\n"
+	runExecTests(t, smallConfig(), execTests{
+		{"SLT.A 1, 2" + src, nil, []int{2}},
+		{"SLT.B 1, 2" + src, nil, []int{1}},
+		{"SLT.AB 1, 2" + src, nil, []int{1}},
+		{"SLT.BA 1, 2" + src, nil, []int{2}},
+		{"SLT.F 1, 2" + src, nil, []int{1}},
+		{"SLT.X 1, 2" + src, nil, []int{1}},
+		{"SLT.I 1, 2" + src, nil, []int{1}},
+		{"SLT.F 1, 2\nDAT.F $3, $4\nDAT.F $5, $6\n", nil, []int{2}},
+		{"SLT.I 1, 2\nDAT.F $3, $4\nMOV.AB #5, @6\n", nil, []int{2}},
+		{"SLT.X 1, 2\nDAT.F $1, $2\nDAT.F $3, $2\n", nil, []int{2}},
+		{"SLT.AB #1, 1\nDAT.F $0, $1\n", nil, []int{1}},
+	})
+}
+
+func TestExecSPL(t *testing.T) {
+	runExecTests(t, smallConfig(), execTests{
+		{"SPL 2\n", nil, []int{1, 2}},
+		{"SPL.A -1\n", nil, []int{1, 79}},
+		{"SPL #3\n", nil, []int{1, 0}},
+	})
+
+	cfg := smallConfig()
+	cfg.MaxProcesses = 1
+	runExecTests(t, cfg, execTests{
+		{"SPL 2\n", nil, []int{1}},
+	})
+}
+
+func TestExecSEQSNE(t *testing.T) {
+	src := "\nDAT.F $3, $4\nDAT.F $3, $5\n"
+	runExecTests(t, smallConfig(), execTests{
+		{"SEQ.A 1, 2" + src, nil, []int{2}},
+		{"SEQ.B 1, 2" + src, nil, []int{1}},
+		{"SEQ.I 1, 2\nDAT.F $3, $4\nDAT.F $3, $4\n", nil, []int{2}},
+		{"SEQ.I 1, 2\nDAT.F $3, $4\nDAT.AB $3, $4\n", nil, []int{1}},
+
+		{"SNE.A 1, 2" + src, nil, []int{1}},
+		{"SNE.B 1, 2" + src, nil, []int{2}},
+		{"SNE.F 1, 2" + src, nil, []int{2}},
+		{"SNE.I 1, 2\nDAT.F $3, $4\nDAT.F $3, $4\n", nil, []int{1}},
+	})
+}
+
+func TestExecNOP(t *testing.T) {
+	runExecTests(t, smallConfig(), execTests{
+		{"NOP.F >1, <2\nDAT.F $0, $0\nDAT.F $0, $0\n", map[int]string{
+			1: "DAT.F $0, $1",
+			2: "DAT.F $0, $79",
+		}, []int{1}},
+	})
+}
+
+func TestExecPSpace(t *testing.T) {
+	src := "\nDAT.F $2, $3\nDAT.F $4, $1\n"
+	tests := []struct {
+		code   string
+		pspace []int
+		core   map[int]string
+	}{
+		{"STP.A 1, 2" + src, []int{-1, 0, 0, 0, 2}, nil},
+		{"STP.B 1, 2" + src, []int{-1, 3, 0, 0, 0}, nil},
+		{"STP.AB 1, 2" + src, []int{-1, 2, 0, 0, 0}, nil},
+		{"STP.BA 1, 2" + src, []int{-1, 0, 0, 0, 3}, nil},
+		{"STP.F 1, 2" + src, []int{-1, 3, 0, 0, 0}, nil},
+		{"STP.AB #7, #12" + src, []int{-1, 0, 7, 0, 0}, nil},
+
+		{"LDP.A 1, 2" + src, []int{-1, 0, 0, 0, 0}, map[int]string{2: "DAT.F $0, $1"}},
+		{"LDP.B 1, 2" + src, []int{-1, 0, 0, 0, 0}, map[int]string{2: "DAT.F $4, $0"}},
+		{"LDP.AB #0, 2" + src, []int{-1, 0, 0, 0, 0}, map[int]string{2: "DAT.F $4, $79"}},
+		{"STP.AB #3, #0" + src, []int{3, 0, 0, 0, 0}, nil},
+	}
+
+	for i, test := range tests {
+		m := newMARS(t, smallConfig(), map[int]string{0: test.code})
+		m.Step()
+
+		if got := m.Warriors()[0].PSpace().Cells(); !slices.Equal(got, test.pspace) {
+			t.Errorf("test %d (%q): got P-space %v, want %v", i, test.code, got, test.pspace)
+		}
+		for addr, want := range test.core {
+			if got := m.Core().Get(addr).String(); got != want {
+				t.Errorf("test %d (%q): cell %d: got %q, want %q", i, test.code, addr, got, want)
+			}
+		}
+		if got := m.Warriors()[0].Processes(); !slices.Equal(got, []int{1}) {
+			t.Errorf("test %d (%q): got processes %v, want [1]", i, test.code, got)
+		}
+	}
+}
+
+func TestExecLDP(t *testing.T) {
+	m := newMARS(t, smallConfig(), map[int]string{0: "STP.AB #9, #3\nLDP.AB #8, 1\nDAT.F $0, $0\n"})
+	m.Step()
+	m.Step()
+
+	if got, want := m.Core().Get(2).String(), "DAT.F $0, $9"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestExecExtendedModes(t *testing.T) {
+	runExecTests(t, smallConfig(), execTests{
+		{"MOV.I }1, *1\nDAT.F $2, $3\nDAT.F $0, $0\nDAT.AB #7, #8\n", map[int]string{
+			1: "DAT.F $3, $3",
+			4: "DAT.AB #7, #8",
+		}, []int{1}},
+		{"MOV.AB #5, {1\nDAT.F $2, $0\nDAT.F $0, $0\n", map[int]string{
+			1: "DAT.F $1, $0",
+			2: "DAT.F $0, $5",
+		}, []int{1}},
+		{"JMP *1\nDAT.F $4, $2\n", nil, []int{5}},
+	})
+}
+
+func TestExecLimits(t *testing.T) {
+	cfg := smallConfig()
+	cfg.ReadLimit, cfg.WriteLimit = 20, 10
+	runExecTests(t, cfg, execTests{
+		{"MOV 0, 5\n", map[int]string{5: "MOV.I $0, $5"}, []int{1}},
+		{"MOV 0, 6\n", map[int]string{6: "DAT.F $0, $0", 76: "MOV.I $0, $6"}, []int{1}},
+		{"JMP 10\n", nil, []int{10}},
+		{"JMP 11\n", nil, []int{71}},
+	})
+}
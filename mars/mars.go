@@ -0,0 +1,198 @@
+// Package mars implements an ICWS'94 Memory Array Redcode Simulator (MARS).
+// Assembled warriors are loaded into a circular core and they take turns
+// executing one instruction each per cycle, in the order they were loaded,
+// until only one of them remains or the cycle limit is reached. Every warrior
+// keeps its processes in a FIFO queue: each turn the process at its head
+// executes and it's then queued back wherever execution should continue.
+// Warriors also get a P-space: private storage surviving across the rounds
+// of a match which they can only reach through LDP and STP.
+package mars
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pcolladosoto/corewarg/assembler"
+)
+
+var ErrTooLong = errors.New("warrior too long")
+
+// Warrior is a warrior loaded into a MARS.
+type Warrior struct {
+	ID     int // position in the loading order
+	Name   string
+	queue  *queue
+	pspace *PSpace // private storage accessed through LDP and STP
+}
+
+// Alive reports whether w has any processes left.
+func (w *Warrior) Alive() bool {
+	return w.queue.len() > 0
+}
+
+// PSpace returns the P-space of w.
+func (w *Warrior) PSpace() *PSpace {
+	return w.pspace
+}
+
+// Processes returns the process counters of w in execution order.
+func (w *Warrior) Processes() []int {
+	return w.queue.list()
+}
+
+// Result is the outcome of a battle.
+type Result struct {
+	Cycles    int        // cycles the battle lasted
+	Survivors []*Warrior // warriors alive at the end
+}
+
+// MARS is a simulator running a single battle.
+type MARS struct {
+	cfg      Config
+	core     *Core
+	warriors []*Warrior
+	cycle    int
+}
+
+// New returns a MARS with an empty core.
+func New(cfg Config) (*MARS, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	core := NewCore(cfg.CoreSize)
+	core.readLimit, core.writeLimit = cfg.limits()
+	return &MARS{cfg: cfg, core: core}, nil
+}
+
+// Core returns the core of m.
+func (m *MARS) Core() *Core {
+	return m.core
+}
+
+// Cycle returns the number of cycles executed so far.
+func (m *MARS) Cycle() int {
+	return m.cycle
+}
+
+// Warriors returns the warriors in m in loading order.
+func (m *MARS) Warriors() []*Warrior {
+	return m.warriors
+}
+
+// Load copies the code of w into the core beginning at addr and queues
+// its first process at its start offset. It's given a blank P-space.
+func (m *MARS) Load(w *assembler.Warrior, addr int) (*Warrior, error) {
+	return m.LoadPSpace(w, addr, NewPSpace(m.cfg.pSpaceSize()))
+}
+
+// LoadPSpace is like Load, but w is given p as its P-space. That's how
+// P-space persists across the rounds of a match.
+func (m *MARS) LoadPSpace(w *assembler.Warrior, addr int, p *PSpace) (*Warrior, error) {
+	if len(w.Code) > m.cfg.MaxLength {
+		return nil, fmt.Errorf("%w: %q has %d instructions, but the maximum is %d", ErrTooLong, w.Name, len(w.Code), m.cfg.MaxLength)
+	}
+
+	m.core.load(addr, w.Code)
+
+	lw := &Warrior{
+		ID:     len(m.warriors),
+		Name:   w.Name,
+		queue:  newQueue(m.cfg.MaxProcesses),
+		pspace: p,
+	}
+	lw.queue.push(m.core.Fold(addr + w.Start))
+	m.warriors = append(m.warriors, lw)
+
+	return lw, nil
+}
+
+// LoadAll loads every warrior in ws at the addresses chosen by p, in order.
+// Their P-spaces come from ps, which can be nil for blank ones.
+func (m *MARS) LoadAll(ws []*assembler.Warrior, ps []*PSpace, p Placement) ([]*Warrior, error) {
+	if ps != nil && len(ps) != len(ws) {
+		return nil, fmt.Errorf("%w: %d P-spaces for %d warriors", ErrPlacement, len(ps), len(ws))
+	}
+
+	addrs, err := p.Place(m.cfg, len(ws))
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make([]*Warrior, 0, len(ws))
+	for i, w := range ws {
+		pspace := NewPSpace(m.cfg.pSpaceSize())
+		if ps != nil {
+			pspace = ps[i]
+		}
+		lw, err := m.LoadPSpace(w, addrs[i], pspace)
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, lw)
+	}
+	return loaded, nil
+}
+
+// alive returns the number of warriors with processes left.
+func (m *MARS) alive() int {
+	n := 0
+	for _, w := range m.warriors {
+		if w.Alive() {
+			n++
+		}
+	}
+	return n
+}
+
+// Done reports whether the battle is over. That's the case once the cycle
+// limit is reached or when a single warrior is left standing. A lonely
+// warrior fights on until it dies.
+func (m *MARS) Done() bool {
+	if m.cycle >= m.cfg.MaxCycles {
+		return true
+	}
+	if len(m.warriors) > 1 {
+		return m.alive() <= 1
+	}
+	return m.alive() == 0
+}
+
+// Step runs a single cycle: every warrior still alive executes
+// the process at the head of its queue. The cycle is cut short as
+// soon as a single warrior is left standing, who's then the winner:
+// the others don't get the chance to die afterwards.
+func (m *MARS) Step() {
+	for _, w := range m.warriors {
+		if !w.Alive() {
+			continue
+		}
+		m.execute(w, w.queue.pop())
+		if len(m.warriors) > 1 && m.alive() <= 1 {
+			break
+		}
+	}
+	m.cycle++
+}
+
+// Run steps m until the battle is over. The result is then recorded in
+// the P-space of every warrior for them to check in the next round.
+func (m *MARS) Run() Result {
+	for !m.Done() {
+		m.Step()
+	}
+
+	r := Result{Cycles: m.cycle}
+	for _, w := range m.warriors {
+		if w.Alive() {
+			r.Survivors = append(r.Survivors, w)
+		}
+	}
+
+	for _, w := range m.warriors {
+		w.pspace.Set(0, ResultLoss)
+		if w.Alive() {
+			w.pspace.Set(0, len(r.Survivors))
+		}
+	}
+	return r
+}
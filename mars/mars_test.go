@@ -0,0 +1,193 @@
+package mars
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/pcolladosoto/corewarg/assembler"
+	"github.com/pcolladosoto/corewarg/parser"
+)
+
+func assemble(t *testing.T, name, in string) *assembler.Warrior {
+	t.Helper()
+	prog, err := parser.ParseString(name, in)
+	if err != nil {
+		t.Fatalf("error parsing %q: %v", name, err)
+	}
+	w, err := assembler.Assemble(prog)
+	if err != nil {
+		t.Fatalf("error assembling %q: %v", name, err)
+	}
+	return w
+}
+
+func newMARS(t *testing.T, cfg Config, warriors map[int]string) *MARS {
+	t.Helper()
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatalf("error creating MARS: %v", err)
+	}
+
+	addrs := []int{}
+	for addr := range warriors {
+		addrs = append(addrs, addr)
+	}
+	slices.Sort(addrs)
+
+	for _, addr := range addrs {
+		if _, err := m.Load(assemble(t, "marsTest", warriors[addr]), addr); err != nil {
+			t.Fatalf("error loading warrior: %v", err)
+		}
+	}
+	return m
+}
+
+func smallConfig() Config {
+	return Config{CoreSize: 80, MaxCycles: 100, MaxProcesses: 8, MaxLength: 10}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		warriors  map[int]string
+		cycles    int
+		survivors []int
+	}{
+		{map[int]string{0: "MOV.I $0, $1\n"}, 100, []int{0}},
+		{map[int]string{0: "DAT #0, #0\n"}, 1, nil},
+		{map[int]string{0: "JMP 1\nJMP 1\nDAT 0\n"}, 3, nil},
+		{map[int]string{0: "MOV.I $0, $1\n", 40: "DAT #0, #0\n"}, 1, []int{0}},
+		{map[int]string{0: "DAT #0, #0\n", 40: "MOV.I $0, $1\n"}, 1, []int{1}},
+		{map[int]string{0: "JMP 0\n", 40: "SPL 0\nJMP -1\n"}, 100, []int{0, 1}},
+		{map[int]string{0: "DAT 0\n", 40: "DAT 0\n"}, 1, []int{1}},
+		{map[int]string{0: "DAT 0\n", 25: "DAT 0\n", 50: "DAT 0\n"}, 1, []int{2}},
+		{map[int]string{0: "JMP 0\n", 25: "DAT 0\n", 50: "DAT 0\n"}, 1, []int{0}},
+	}
+
+	for i, test := range tests {
+		r := newMARS(t, smallConfig(), test.warriors).Run()
+		if r.Cycles != test.cycles {
+			t.Errorf("test %d: battle lasted %d cycles, want %d", i, r.Cycles, test.cycles)
+		}
+
+		survivors := []int{}
+		for _, w := range r.Survivors {
+			survivors = append(survivors, w.ID)
+		}
+		if !slices.Equal(survivors, test.survivors) {
+			t.Errorf("test %d: got survivors %v, want %v", i, survivors, test.survivors)
+		}
+	}
+}
+
+func TestImpCopiesItself(t *testing.T) {
+	m := newMARS(t, smallConfig(), map[int]string{10: "MOV.I $0, $1\n"})
+	for i := 0; i < 5; i++ {
+		m.Step()
+	}
+
+	for addr := 10; addr <= 15; addr++ {
+		if got := m.Core().Get(addr); got.Opcode != parser.MOV {
+			t.Errorf("cell %d: got %s, want the imp", addr, got)
+		}
+	}
+	if got := m.Warriors()[0].Processes(); !slices.Equal(got, []int{15}) {
+		t.Errorf("got processes %v, want [15]", got)
+	}
+}
+
+func TestProcessQueue(t *testing.T) {
+	m := newMARS(t, smallConfig(), map[int]string{78: "SPL 2\nDAT 0\nJMP -2\n"})
+	w := m.Warriors()[0]
+
+	// note the warrior wraps around the end of the core
+	want := [][]int{
+		{79, 0}, // SPL queues the next instruction first
+		{0},     // the DAT kills the process at 79
+		{78},    // JMP -2 goes back to the SPL
+		{79, 0}, // and so on...
+		{0},
+	}
+	for i, pcs := range want {
+		m.Step()
+		if got := w.Processes(); !slices.Equal(got, pcs) {
+			t.Errorf("cycle %d: got processes %v, want %v", i+1, got, pcs)
+		}
+	}
+}
+
+func TestMaxProcesses(t *testing.T) {
+	cfg := smallConfig()
+	cfg.MaxProcesses = 3
+	m := newMARS(t, cfg, map[int]string{0: "SPL 0\nJMP -1\n", 40: "SPL 0\nJMP -1\n"})
+
+	for i := 0; i < 10; i++ {
+		m.Step()
+	}
+	for _, w := range m.Warriors() {
+		if n := len(w.Processes()); n != cfg.MaxProcesses {
+			t.Errorf("warrior %d: got %d processes, want %d", w.ID, n, cfg.MaxProcesses)
+		}
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	// the first warrior bombs the second one before it gets to run,
+	// so it wins even though it would die on its next turn
+	m := newMARS(t, smallConfig(), map[int]string{0: "MOV 1, 40\nDAT 0\n", 40: "MOV.I $0, $1\n"})
+	r := m.Run()
+	if r.Cycles != 1 || len(r.Survivors) != 1 || r.Survivors[0].ID != 0 {
+		t.Errorf("got %d cycles and survivors %v, want 1 cycle and the first warrior", r.Cycles, r.Survivors)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	m, err := New(smallConfig())
+	if err != nil {
+		t.Fatalf("error creating MARS: %v", err)
+	}
+
+	long := &assembler.Warrior{Name: "long", Code: make([]assembler.Instruction, 11)}
+	if _, err := m.Load(long, 0); !errors.Is(err, ErrTooLong) {
+		t.Errorf("got %v, want %v", err, ErrTooLong)
+	}
+
+	for i, cfg := range []Config{
+		{CoreSize: 0, MaxCycles: 1, MaxProcesses: 1, MaxLength: 1},
+		{CoreSize: 10, MaxCycles: 0, MaxProcesses: 1, MaxLength: 1},
+		{CoreSize: 10, MaxCycles: 1, MaxProcesses: 0, MaxLength: 1},
+		{CoreSize: 10, MaxCycles: 1, MaxProcesses: 1, MaxLength: 11},
+		{CoreSize: 10, MaxCycles: 1, MaxProcesses: 1, MaxLength: 1, PSpaceSize: -1},
+		{CoreSize: 10, MaxCycles: 1, MaxProcesses: 1, MaxLength: 1, ReadLimit: 11},
+		{CoreSize: 10, MaxCycles: 1, MaxProcesses: 1, MaxLength: 1, WriteLimit: -1},
+		{CoreSize: 10, MaxCycles: 1, MaxProcesses: 1, MaxLength: 1, ReadLimit: 3},
+		{CoreSize: 80, MaxCycles: 1, MaxProcesses: 1, MaxLength: 1, WriteLimit: 7},
+		{CoreSize: 10, MaxCycles: 1, MaxProcesses: 1, MaxLength: 5, MinDistance: 4},
+	} {
+		if _, err := New(cfg); !errors.Is(err, ErrBadConfig) {
+			t.Errorf("config %d: got %v, want %v", i, err, ErrBadConfig)
+		}
+	}
+}
+
+func TestLimitsDivideCore(t *testing.T) {
+	for _, lim := range []int{1, 2, 5, 16, 40, 80} {
+		cfg := smallConfig()
+		cfg.ReadLimit, cfg.WriteLimit = lim, lim
+		if _, err := New(cfg); err != nil {
+			t.Errorf("limit %d: unexpected error: %v", lim, err)
+		}
+	}
+
+	for _, lim := range []int{3, 7, 30, 79} {
+		cfg := smallConfig()
+		cfg.ReadLimit = lim
+		if _, err := New(cfg); !errors.Is(err, ErrBadConfig) {
+			t.Errorf("read limit %d: got %v, want %v", lim, err, ErrBadConfig)
+		}
+		cfg.ReadLimit, cfg.WriteLimit = 0, lim
+		if _, err := New(cfg); !errors.Is(err, ErrBadConfig) {
+			t.Errorf("write limit %d: got %v, want %v", lim, err, ErrBadConfig)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package mars
+
+import "github.com/pcolladosoto/corewarg/parser"
+
+// Registers are the registers of the ICWS'94 reference emulator. The
+// instruction being executed and the ones its operands point to are copied
+// over before execution: opcodes work on these copies rather than on the
+// core, which they only write to.
+type Registers struct {
+	IR       Instruction // the instruction being executed
+	IRA, IRB Instruction // what the A and B operands point to
+	RPA, WPA int         // A read and write pointers, relative to the PC
+	RPB, WPB int         // B read and write pointers, relative to the PC
+}
+
+// Evaluate carries out the operand evaluation phase of the instruction at
+// pc. The A operand is evaluated first and then comes the B one. For each of
+// them, predecrements (<, {) are applied before following the pointer and
+// postincrements (>, }) right after copying the instruction it points to into
+// the corresponding register. Note these take place in the core, so the B
+// operand sees whatever the A operand did. The instruction register is
+// copied beforehand, so the operands are evaluated as they were fetched
+// even if the instruction changes along the way.
+func (c *Core) Evaluate(pc int) Registers {
+	r := Registers{IR: c.Get(pc)}
+	r.RPA, r.WPA, r.IRA = c.operand(pc, r.IR.AMode, r.IR.AField)
+	r.RPB, r.WPB, r.IRB = c.operand(pc, r.IR.BMode, r.IR.BField)
+	return r
+}
+
+// operand evaluates a single operand of the instruction at pc. It returns
+// its read and write pointers together with the instruction read through it.
+// Both pointers are folded through the read and write limits: an indirect
+// operand goes through the field it reads for the read pointer and through
+// the one it updates for the write one.
+func (c *Core) operand(pc int, mode parser.AddressingMode, field int) (int, int, Instruction) {
+	if mode == parser.Hash {
+		return 0, 0, c.Get(pc)
+	}
+
+	rp, wp := c.limit(field, c.readLimit), c.limit(field, c.writeLimit)
+	if mode == parser.Dollar {
+		return rp, wp, c.Get(pc + rp)
+	}
+
+	// Every other mode is indirect through one of the fields of the
+	// intermediate instruction, which might need to be decremented first.
+	pointer := func(i *Instruction) *int { return &i.BField }
+	switch mode {
+	case parser.Asterisk, parser.LBrace, parser.RBrace:
+		pointer = func(i *Instruction) *int { return &i.AField }
+	}
+
+	inter := pointer(c.cell(pc + wp))
+	if mode == parser.Lt || mode == parser.LBrace {
+		*inter = c.Fold(*inter - 1)
+	}
+
+	rp = c.limit(rp+*pointer(c.cell(pc + rp)), c.readLimit)
+	wp = c.limit(wp+*inter, c.writeLimit)
+	ir := c.Get(pc + rp)
+
+	if mode == parser.Gt || mode == parser.RBrace {
+		*inter = c.Fold(*inter + 1)
+	}
+
+	return rp, wp, ir
+}
@@ -0,0 +1,191 @@
+package mars
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		code     string
+		ptrs     [4]int // RPA, WPA, RPB, WPB
+		ir       string
+		ira, irb string
+		core     map[int]string // expected content of these addresses
+	}{
+		{
+			"MOV #5, $2\n",
+			[4]int{0, 0, 2, 2}, "MOV.AB #5, $2", "MOV.AB #5, $2", "DAT.F $0, $0", nil,
+		},
+		{
+			"MOV @1, 0\nDAT 0, 2\nDAT 0, 0\nDAT 7, 0\n",
+			[4]int{3, 3, 0, 0}, "MOV.I @1, $0", "DAT.F $7, $0", "MOV.I @1, $0",
+			map[int]string{1: "DAT.F $0, $2"},
+		},
+		{
+			// the pointer is decremented before following it
+			"MOV <1, 0\nDAT 0, 2\nDAT 5, 0\n",
+			[4]int{2, 2, 0, 0}, "MOV.I <1, $0", "DAT.F $5, $0", "MOV.I <1, $0",
+			map[int]string{1: "DAT.F $0, $1"},
+		},
+		{
+			"MOV <1, 0\nDAT 0, 0\n",
+			[4]int{0, 0, 0, 0}, "MOV.I <1, $0", "MOV.I <1, $0", "MOV.I <1, $0",
+			map[int]string{1: "DAT.F $0, $79"},
+		},
+		{
+			// the pointer is incremented after copying what it points to
+			"MOV >1, 0\nDAT 0, 2\nDAT 0, 0\nDAT 7, 0\n",
+			[4]int{3, 3, 0, 0}, "MOV.I >1, $0", "DAT.F $7, $0", "MOV.I >1, $0",
+			map[int]string{1: "DAT.F $0, $3"},
+		},
+		{
+			"MOV >1, 0\nDAT 0, 0\n",
+			[4]int{1, 1, 0, 0}, "MOV.I >1, $0", "DAT.F $0, $0", "MOV.I >1, $0",
+			map[int]string{1: "DAT.F $0, $1"},
+		},
+		{
+			"MOV 0, <1\nDAT 0, 3\n",
+			[4]int{0, 0, 3, 3}, "MOV.I $0, <1", "MOV.I $0, <1", "DAT.F $0, $0",
+			map[int]string{1: "DAT.F $0, $2"},
+		},
+		{
+			"MOV 0, >1\nDAT 0, 0\n",
+			[4]int{0, 0, 1, 1}, "MOV.I $0, >1", "MOV.I $0, >1", "DAT.F $0, $0",
+			map[int]string{1: "DAT.F $0, $1"},
+		},
+		{
+			// the B operand sees the increment carried out by the A one
+			"MOV >1, @1\nDAT 0, 2\n",
+			[4]int{3, 3, 4, 4}, "MOV.I >1, @1", "DAT.F $0, $0", "DAT.F $0, $0",
+			map[int]string{1: "DAT.F $0, $3"},
+		},
+		{
+			// the B operand is evaluated as fetched, not as modified by the A one
+			"MOV <0, 1\n",
+			[4]int{0, 0, 1, 1}, "MOV.I <0, $1", "MOV.I <0, $0", "DAT.F $0, $0",
+			map[int]string{0: "MOV.I <0, $0"},
+		},
+		{
+			// immediate B operands read whatever's in the core by then
+			"MOV <0, #1\n",
+			[4]int{0, 0, 0, 0}, "MOV.B <0, #1", "MOV.B <0, #0", "MOV.B <0, #0",
+			map[int]string{0: "MOV.B <0, #0"},
+		},
+		{
+			"MOV *1, 0\nDAT 2, 0\nDAT 0, 0\nDAT 7, 0\n",
+			[4]int{3, 3, 0, 0}, "MOV.I *1, $0", "DAT.F $7, $0", "MOV.I *1, $0",
+			map[int]string{1: "DAT.F $2, $0"},
+		},
+		{
+			"MOV {1, 0\nDAT 2, 0\nDAT 5, 0\n",
+			[4]int{2, 2, 0, 0}, "MOV.I {1, $0", "DAT.F $5, $0", "MOV.I {1, $0",
+			map[int]string{1: "DAT.F $1, $0"},
+		},
+		{
+			"MOV }1, 0\nDAT 2, 0\nDAT 0, 0\nDAT 7, 0\n",
+			[4]int{3, 3, 0, 0}, "MOV.I }1, $0", "DAT.F $7, $0", "MOV.I }1, $0",
+			map[int]string{1: "DAT.F $3, $0"},
+		},
+		{
+			// A-field modes leave the B-field alone and the other way around
+			"MOV 0, }1\nDAT 0, 5\n",
+			[4]int{0, 0, 1, 1}, "MOV.I $0, }1", "MOV.I $0, }1", "DAT.F $0, $5",
+			map[int]string{1: "DAT.F $1, $5"},
+		},
+		{
+			"MOV 0, {1\nDAT 0, 5\n",
+			[4]int{0, 0, 0, 0}, "MOV.I $0, {1", "MOV.I $0, {1", "MOV.I $0, {1",
+			map[int]string{1: "DAT.F $79, $5"},
+		},
+	}
+
+	for i, test := range tests {
+		m := newMARS(t, smallConfig(), map[int]string{0: test.code})
+		r := m.Core().Evaluate(0)
+
+		if got := [4]int{r.RPA, r.WPA, r.RPB, r.WPB}; got != test.ptrs {
+			t.Errorf("test %d (%q): got pointers %v, want %v", i, test.code, got, test.ptrs)
+		}
+		for _, reg := range []struct {
+			name      string
+			got, want string
+		}{{"IR", r.IR.String(), test.ir}, {"IRA", r.IRA.String(), test.ira}, {"IRB", r.IRB.String(), test.irb}} {
+			if reg.got != reg.want {
+				t.Errorf("test %d (%q): got %s %q, want %q", i, test.code, reg.name, reg.got, reg.want)
+			}
+		}
+		for addr, want := range test.core {
+			if got := m.Core().Get(addr).String(); got != want {
+				t.Errorf("test %d (%q): cell %d: got %q, want %q", i, test.code, addr, got, want)
+			}
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		ptr, lim, want int
+	}{
+		{0, 80, 0},
+		{40, 80, 40},
+		{41, 80, 41},
+		{79, 80, 79},
+		{85, 80, 5},
+		{0, 20, 0},
+		{10, 20, 10},
+		{11, 20, 71},
+		{19, 20, 79},
+		{20, 20, 0},
+		{70, 20, 10},
+		{71, 20, 71},
+		{79, 20, 79},
+		{158, 20, 78},
+		{0, 1, 0},
+		{1, 1, 0},
+	}
+
+	c := NewCore(80)
+	for _, test := range tests {
+		if got := c.limit(test.ptr, test.lim); got != test.want {
+			t.Errorf("limit(%d, %d): got %d, want %d", test.ptr, test.lim, got, test.want)
+		}
+	}
+}
+
+func TestEvaluateLimits(t *testing.T) {
+	tests := []struct {
+		code        string
+		read, write int
+		ptrs        [4]int // RPA, WPA, RPB, WPB
+		core        map[int]string
+	}{
+		{"MOV 5, 6\n", 10, 10, [4]int{5, 5, 76, 76}, nil},
+		{"MOV 5, 6\n", 0, 10, [4]int{5, 5, 6, 76}, nil},
+		{"MOV 6, 5\n", 10, 0, [4]int{76, 6, 5, 5}, nil},
+		{"MOV -5, -6\n", 10, 10, [4]int{5, 5, 4, 4}, nil},
+		{"MOV 0, @1\nDAT 0, 4\n", 10, 10, [4]int{0, 0, 5, 5}, nil},
+		{"MOV 0, @1\nDAT 0, 5\n", 10, 10, [4]int{0, 0, 76, 76}, nil},
+		{"MOV 0, @1\nDAT 0, 5\n", 0, 10, [4]int{0, 0, 6, 76}, nil},
+		{
+			// the read pointer goes through the cell the read limit
+			// lets it see while the write one decrements another one
+			"MOV <6, 0\n", 10, 0,
+			[4]int{76, 5, 0, 0},
+			map[int]string{6: "DAT.F $0, $79", 76: "DAT.F $0, $0"},
+		},
+	}
+
+	for i, test := range tests {
+		cfg := smallConfig()
+		cfg.ReadLimit, cfg.WriteLimit = test.read, test.write
+		m := newMARS(t, cfg, map[int]string{0: test.code})
+		r := m.Core().Evaluate(0)
+
+		if got := [4]int{r.RPA, r.WPA, r.RPB, r.WPB}; got != test.ptrs {
+			t.Errorf("test %d (%q): got pointers %v, want %v", i, test.code, got, test.ptrs)
+		}
+		for addr, want := range test.core {
+			if got := m.Core().Get(addr).String(); got != want {
+				t.Errorf("test %d (%q): cell %d: got %q, want %q", i, test.code, addr, got, want)
+			}
+		}
+	}
+}
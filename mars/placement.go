@@ -0,0 +1,98 @@
+package mars
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+)
+
+var (
+	ErrNoRoom    = errors.New("not enough room in the core")
+	ErrTooClose  = errors.New("warriors too close")
+	ErrPlacement = errors.New("bad placement")
+)
+
+// Placement chooses the addresses n warriors are loaded at. The first one
+// always goes at address 0 and the rest must be at least the minimum
+// distance away from each other, measured around the core.
+type Placement interface {
+	Place(cfg Config, n int) ([]int, error)
+}
+
+// RandomPlacement places warriors at random. The same seed always yields
+// the same sequence of placements, so battles can be reproduced.
+type RandomPlacement struct {
+	rng *rand.Rand
+}
+
+// NewRandomPlacement returns a RandomPlacement seeded with seed.
+func NewRandomPlacement(seed uint64) *RandomPlacement {
+	return &RandomPlacement{rng: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// Place spreads the slack left in the core once every warrior has been
+// given its minimum distance at random among the gaps between them. With
+// two warriors this is what pMARS does: the second one ends up anywhere
+// within [d, size-d].
+func (p *RandomPlacement) Place(cfg Config, n int) ([]int, error) {
+	d := cfg.minDistance()
+	if n*d > cfg.CoreSize {
+		return nil, fmt.Errorf("%w: %d warriors %d cells apart need %d cells, but there are %d", ErrNoRoom, n, d, n*d, cfg.CoreSize)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	slack := make([]int, n-1)
+	for i := range slack {
+		slack[i] = p.rng.IntN(cfg.CoreSize - n*d + 1)
+	}
+	slices.Sort(slack)
+
+	addrs := []int{0}
+	for i, s := range slack {
+		addrs = append(addrs, (i+1)*d+s)
+	}
+
+	// don't always put the second warrior closest to the first one
+	p.rng.Shuffle(len(addrs)-1, func(i, j int) {
+		addrs[i+1], addrs[j+1] = addrs[j+1], addrs[i+1]
+	})
+	return addrs, nil
+}
+
+// FixedPlacement places warriors at the given addresses, which is handy
+// for tests. Placing two warriors with FixedPlacement{0, pos} is the
+// same as pMARS's -F pos.
+type FixedPlacement []int
+
+// Place returns the addresses in p after checking they're far enough apart.
+func (p FixedPlacement) Place(cfg Config, n int) ([]int, error) {
+	if len(p) != n {
+		return nil, fmt.Errorf("%w: %d addresses for %d warriors", ErrPlacement, len(p), n)
+	}
+	if n > 0 && p[0] != 0 {
+		return nil, fmt.Errorf("%w: the first warrior must be at 0, got %d", ErrPlacement, p[0])
+	}
+
+	d := cfg.minDistance()
+	for i, a := range p {
+		if a < 0 || a >= cfg.CoreSize {
+			return nil, fmt.Errorf("%w: address %d is outside the core", ErrPlacement, a)
+		}
+		for _, b := range p[:i] {
+			if dist := distance(a, b, cfg.CoreSize); dist < d {
+				return nil, fmt.Errorf("%w: %d and %d are %d cells apart, but the minimum is %d", ErrTooClose, b, a, dist, d)
+			}
+		}
+	}
+	return slices.Clone(p), nil
+}
+
+// distance returns how far apart a and b are going around
+// a core of the given size whichever way is shorter.
+func distance(a, b, size int) int {
+	d := fold(a-b, size)
+	return min(d, size-d)
+}
@@ -0,0 +1,134 @@
+package mars
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/pcolladosoto/corewarg/assembler"
+)
+
+func TestRandomPlacement(t *testing.T) {
+	cfg := smallConfig()
+	for n := 1; n <= 8; n++ {
+		p := NewRandomPlacement(uint64(n))
+		for range 100 {
+			addrs, err := p.Place(cfg, n)
+			if err != nil {
+				t.Fatalf("%d warriors: unexpected error: %v", n, err)
+			}
+			if len(addrs) != n || addrs[0] != 0 {
+				t.Fatalf("%d warriors: got addresses %v", n, addrs)
+			}
+			if _, err := FixedPlacement(addrs).Place(cfg, n); err != nil {
+				t.Fatalf("%d warriors: addresses %v: %v", n, addrs, err)
+			}
+		}
+	}
+}
+
+func TestRandomPlacementRange(t *testing.T) {
+	cfg := smallConfig()
+	cfg.MinDistance = 30
+
+	seen := map[int]bool{}
+	p := NewRandomPlacement(1)
+	for range 1000 {
+		addrs, err := p.Place(cfg, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if addrs[1] < 30 || addrs[1] > 50 {
+			t.Fatalf("second warrior at %d, outside [30, 50]", addrs[1])
+		}
+		seen[addrs[1]] = true
+	}
+	if len(seen) != 21 {
+		t.Errorf("got %d different positions, want 21", len(seen))
+	}
+}
+
+func TestRandomPlacementSeed(t *testing.T) {
+	place := func(seed uint64) [][]int {
+		p, got := NewRandomPlacement(seed), [][]int{}
+		for range 10 {
+			addrs, err := p.Place(smallConfig(), 3)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got = append(got, addrs)
+		}
+		return got
+	}
+
+	eq := func(a, b [][]int) bool { return slices.EqualFunc(a, b, slices.Equal) }
+	if a, b := place(42), place(42); !eq(a, b) {
+		t.Errorf("same seed, different placements: %v and %v", a, b)
+	}
+	if a, b := place(42), place(43); eq(a, b) {
+		t.Errorf("different seeds, same placements: %v", a)
+	}
+}
+
+func TestPlacementErrors(t *testing.T) {
+	cfg := smallConfig()
+	tests := []struct {
+		p    Placement
+		n    int
+		want error
+	}{
+		{NewRandomPlacement(0), 9, ErrNoRoom},
+		{FixedPlacement{0, 9}, 2, ErrTooClose},
+		{FixedPlacement{0, 71}, 2, ErrTooClose},
+		{FixedPlacement{0, 40, 45}, 3, ErrTooClose},
+		{FixedPlacement{0, 40}, 3, ErrPlacement},
+		{FixedPlacement{10, 40}, 2, ErrPlacement},
+		{FixedPlacement{0, 80}, 2, ErrPlacement},
+	}
+
+	for i, test := range tests {
+		if _, err := test.p.Place(cfg, test.n); !errors.Is(err, test.want) {
+			t.Errorf("test %d: got %v, want %v", i, err, test.want)
+		}
+	}
+
+	for i, p := range []FixedPlacement{{0, 10}, {0, 70}, {0, 20, 40, 60}} {
+		if _, err := p.Place(cfg, len(p)); err != nil {
+			t.Errorf("placement %d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestLoadAll(t *testing.T) {
+	ws := []*assembler.Warrior{
+		assemble(t, "imp", "MOV 0, 1\n"),
+		assemble(t, "dwarf", "ORG start\nbomb DAT #0, #0\nstart ADD #4, bomb\nMOV bomb, @bomb\nJMP start\n"),
+	}
+
+	m, err := New(smallConfig())
+	if err != nil {
+		t.Fatalf("error creating MARS: %v", err)
+	}
+	loaded, err := m.LoadAll(ws, nil, FixedPlacement{0, 35})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, want := range [][]int{{0}, {36}} {
+		if got := loaded[i].Processes(); !slices.Equal(got, want) {
+			t.Errorf("warrior %d: got processes %v, want %v", i, got, want)
+		}
+	}
+	for addr, want := range map[int]string{0: "MOV.I $0, $1", 35: "DAT.F #0, #0", 38: "JMP.B $78, $0"} {
+		if got := m.Core().Get(addr).String(); got != want {
+			t.Errorf("cell %d: got %q, want %q", addr, got, want)
+		}
+	}
+
+	if _, err := m.LoadAll(ws, nil, FixedPlacement{0, 5}); !errors.Is(err, ErrTooClose) {
+		t.Errorf("got %v, want %v", err, ErrTooClose)
+	}
+	if _, err := m.LoadAll(ws, []*PSpace{NewPSpace(5)}, FixedPlacement{0, 40}); !errors.Is(err, ErrPlacement) {
+		t.Errorf("got %v, want %v", err, ErrPlacement)
+	}
+}
@@ -0,0 +1,76 @@
+package mars
+
+import "github.com/pcolladosoto/corewarg/assembler"
+
+// Results stored in the first cell of P-space.
+const (
+	ResultNone = -1 // there was no previous round
+	ResultLoss = 0  // the warrior died in the previous round
+)
+
+// PSpace is the private storage of a warrior. It outlives any single MARS
+// so that it persists across the rounds of a match. The first cell always
+// holds the result of the previous round: ResultNone on the first one,
+// ResultLoss if the warrior died and the number of survivors otherwise.
+type PSpace struct {
+	result int
+	cells  []int // cells[0] is never used: it's result instead
+}
+
+// NewPSpace returns a blank P-space with size cells.
+func NewPSpace(size int) *PSpace {
+	cells := make([]int, max(size, 1))
+	return &PSpace{result: ResultNone, cells: cells}
+}
+
+// NewPSpaces returns the P-spaces of the warriors in ws, which fight a match
+// together. Warriors declaring the same PIN share every cell except for the
+// first one: the result of the previous round is always private.
+func NewPSpaces(cfg Config, ws []*assembler.Warrior) []*PSpace {
+	pins := map[int]*PSpace{}
+	ps := make([]*PSpace, len(ws))
+	for i, w := range ws {
+		ps[i] = NewPSpace(cfg.pSpaceSize())
+		if w.PIN == nil {
+			continue
+		}
+		if shared, ok := pins[*w.PIN]; ok {
+			ps[i].cells = shared.cells
+			continue
+		}
+		pins[*w.PIN] = ps[i]
+	}
+	return ps
+}
+
+// Size returns the number of cells in p.
+func (p *PSpace) Size() int {
+	return len(p.cells)
+}
+
+// Get returns the value in the cell at addr, which wraps around.
+func (p *PSpace) Get(addr int) int {
+	addr = fold(addr, p.Size())
+	if addr == 0 {
+		return p.result
+	}
+	return p.cells[addr]
+}
+
+// Set stores v in the cell at addr, which wraps around.
+func (p *PSpace) Set(addr, v int) {
+	addr = fold(addr, p.Size())
+	if addr == 0 {
+		p.result = v
+		return
+	}
+	p.cells[addr] = v
+}
+
+// Cells returns a copy of the contents of p.
+func (p *PSpace) Cells() []int {
+	cells := make([]int, p.Size())
+	copy(cells, p.cells)
+	cells[0] = p.result
+	return cells
+}
@@ -0,0 +1,96 @@
+package mars
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pcolladosoto/corewarg/assembler"
+)
+
+func TestPSpaceWraps(t *testing.T) {
+	p := NewPSpace(5)
+	p.Set(7, 3)
+	p.Set(-1, 4)
+	p.Set(5, 9)
+
+	if got, want := p.Cells(), []int{9, 0, 3, 0, 4}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := p.Get(-3); got != 3 {
+		t.Errorf("got %d, want 3", got)
+	}
+}
+
+func TestNewPSpaces(t *testing.T) {
+	ws := []*assembler.Warrior{
+		assemble(t, "a", "PIN 7\nDAT 0\n"),
+		assemble(t, "b", "DAT 0\n"),
+		assemble(t, "c", "PIN 7\nDAT 0\n"),
+		assemble(t, "d", "PIN 3\nDAT 0\n"),
+	}
+	ps := NewPSpaces(smallConfig(), ws)
+
+	for i, p := range ps {
+		p.Set(0, i)
+		p.Set(i+1, 10+i)
+	}
+
+	want := [][]int{
+		{0, 10, 0, 12, 0},
+		{1, 0, 11, 0, 0},
+		{2, 10, 0, 12, 0},
+		{3, 0, 0, 0, 13},
+	}
+	for i, p := range ps {
+		if got := p.Cells(); !slices.Equal(got, want[i]) {
+			t.Errorf("warrior %d: got P-space %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+// TestPSpaceRounds runs three rounds in which a warrior keeps a counter in
+// its P-space and uses it to store the result of every previous round. It
+// survives the first round on its own, dies in the second one and ties
+// with its opponent in the third one.
+func TestPSpaceRounds(t *testing.T) {
+	w := assemble(t, "counter",
+		"      LDP.AB #4, cnt\n"+
+			"      LDP.AB #0, res\n"+
+			"      ADD.AB #1, cnt\n"+
+			"      STP.B  cnt, #4\n"+
+			"      STP.B  res, cnt\n"+
+			"loop  JMP    loop\n"+
+			"cnt   DAT    #0, #0\n"+
+			"res   DAT    #0, #0\n")
+	opponents := []struct {
+		code      string
+		survivors int
+	}{
+		{"NOP 0\nNOP 0\nNOP 0\nNOP 0\nNOP 0\nNOP 0\nDAT 0\n", 1},
+		{"MOV 2, -35\nJMP 0\nDAT 0\n", 1},
+		{"JMP 0\n", 2},
+	}
+
+	cfg := smallConfig()
+	ps := NewPSpaces(cfg, []*assembler.Warrior{w})
+	for round, opponent := range opponents {
+		m, err := New(cfg)
+		if err != nil {
+			t.Fatalf("error creating MARS: %v", err)
+		}
+		if _, err := m.LoadPSpace(w, 0, ps[0]); err != nil {
+			t.Fatalf("round %d: error loading warrior: %v", round, err)
+		}
+		if _, err := m.Load(assemble(t, "opponent", opponent.code), 40); err != nil {
+			t.Fatalf("round %d: error loading opponent: %v", round, err)
+		}
+		if r := m.Run(); len(r.Survivors) != opponent.survivors {
+			t.Fatalf("round %d: got %d survivors, want %d", round, len(r.Survivors), opponent.survivors)
+		}
+	}
+
+	// the first round's result is folded into the core when loaded
+	if got, want := ps[0].Cells(), []int{2, 79, 1, 0, 3}; !slices.Equal(got, want) {
+		t.Errorf("got P-space %v, want %v", got, want)
+	}
+}
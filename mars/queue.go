@@ -0,0 +1,47 @@
+package mars
+
+// queue is a warrior's FIFO of process counters. It's backed by
+// a ring buffer, as it can never hold more than MaxProcesses.
+type queue struct {
+	pcs   []int
+	head  int // index of the next process to run
+	count int
+}
+
+func newQueue(capacity int) *queue {
+	return &queue{pcs: make([]int, capacity)}
+}
+
+func (q *queue) len() int {
+	return q.count
+}
+
+func (q *queue) full() bool {
+	return q.count == len(q.pcs)
+}
+
+// push appends pc to the end of the queue. It's a no-op if the queue is full.
+func (q *queue) push(pc int) {
+	if q.full() {
+		return
+	}
+	q.pcs[(q.head+q.count)%len(q.pcs)] = pc
+	q.count++
+}
+
+// pop removes and returns the process counter at the head of the queue.
+func (q *queue) pop() int {
+	pc := q.pcs[q.head]
+	q.head = (q.head + 1) % len(q.pcs)
+	q.count--
+	return pc
+}
+
+// list returns the process counters in execution order.
+func (q *queue) list() []int {
+	pcs := make([]int, q.count)
+	for i := range pcs {
+		pcs[i] = q.pcs[(q.head+i)%len(q.pcs)]
+	}
+	return pcs
+}
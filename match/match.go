@@ -0,0 +1,117 @@
+// Package match runs matches between warriors: a number of rounds, each of
+// them a fresh battle in its own MARS. Warriors are placed anew at random
+// every round and the order they execute in is rotated so that none of
+// them gets to always go first. The outcome of every round is scored just
+// like KotH hills do: 3 points for a win and 1 point for a tie.
+package match
+
+import (
+	"fmt"
+
+	"github.com/pcolladosoto/corewarg/assembler"
+	"github.com/pcolladosoto/corewarg/mars"
+)
+
+// Points awarded for the outcome of a round.
+const (
+	WinPoints = 3
+	TiePoints = 1
+)
+
+// Config holds the parameters of a match.
+type Config struct {
+	mars.Config
+	Rounds    int            // number of battles to fight
+	Seed      uint64         // seed for the random placement
+	Placement mars.Placement // placement to use instead of the random one
+}
+
+// DefaultConfig returns pMARS's defaults, which fight 1 round.
+func DefaultConfig() Config {
+	return Config{Config: mars.DefaultConfig(), Rounds: 1}
+}
+
+// Score is how a warrior fared throughout a match.
+type Score struct {
+	Name   string
+	Wins   int // rounds it was the only survivor
+	Ties   int // rounds it survived along with someone else
+	Losses int // rounds it died
+}
+
+// Points returns the score of s as a KotH hill would compute it.
+func (s Score) Points() int {
+	return WinPoints*s.Wins + TiePoints*s.Ties
+}
+
+// Result is the outcome of a match.
+type Result struct {
+	Rounds int
+	Scores []Score // one per warrior in the order they were given
+}
+
+// Run fights a match between ws. Each warrior keeps its P-space across rounds.
+// Given the same configuration the outcome is always the same.
+func Run(cfg Config, ws []*assembler.Warrior) (*Result, error) {
+	if cfg.Rounds < 1 {
+		return nil, fmt.Errorf("%w: rounds must be positive, got %d", mars.ErrBadConfig, cfg.Rounds)
+	}
+	placement := cfg.Placement
+	if placement == nil {
+		placement = mars.NewRandomPlacement(cfg.Seed)
+	}
+
+	r := &Result{Rounds: cfg.Rounds, Scores: make([]Score, len(ws))}
+	for i, w := range ws {
+		r.Scores[i].Name = w.Name
+	}
+
+	ps := mars.NewPSpaces(cfg.Config, ws)
+	for round := range cfg.Rounds {
+		survivors, err := fight(cfg.Config, ws, ps, placement, round)
+		if err != nil {
+			return nil, fmt.Errorf("round %d: %w", round+1, err)
+		}
+		for i := range r.Scores {
+			switch {
+			case !survivors[i]:
+				r.Scores[i].Losses++
+			case len(survivors) == 1:
+				r.Scores[i].Wins++
+			default:
+				r.Scores[i].Ties++
+			}
+		}
+	}
+	return r, nil
+}
+
+// fight runs a single round and returns the indices in ws of the survivors.
+// Warriors are placed in the order they're given, but the first one to
+// execute is the one at index round, wrapping around.
+func fight(cfg mars.Config, ws []*assembler.Warrior, ps []*mars.PSpace, placement mars.Placement, round int) (map[int]bool, error) {
+	m, err := mars.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := placement.Place(cfg, len(ws))
+	if err != nil {
+		return nil, err
+	}
+
+	order := map[*mars.Warrior]int{}
+	for k := range ws {
+		i := (round + k) % len(ws)
+		w, err := m.LoadPSpace(ws[i], addrs[i], ps[i])
+		if err != nil {
+			return nil, err
+		}
+		order[w] = i
+	}
+
+	survivors := map[int]bool{}
+	for _, w := range m.Run().Survivors {
+		survivors[order[w]] = true
+	}
+	return survivors, nil
+}
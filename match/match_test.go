@@ -0,0 +1,123 @@
+package match
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pcolladosoto/corewarg/assembler"
+	"github.com/pcolladosoto/corewarg/mars"
+	"github.com/pcolladosoto/corewarg/parser"
+)
+
+func assemble(t *testing.T, name, in string) *assembler.Warrior {
+	t.Helper()
+	prog, err := parser.ParseString(name, in)
+	if err != nil {
+		t.Fatalf("error parsing %q: %v", name, err)
+	}
+	w, err := assembler.Assemble(prog)
+	if err != nil {
+		t.Fatalf("error assembling %q: %v", name, err)
+	}
+	return w
+}
+
+func smallConfig(rounds int) Config {
+	return Config{
+		Config: mars.Config{CoreSize: 80, MaxCycles: 200, MaxProcesses: 8, MaxLength: 10},
+		Rounds: rounds,
+	}
+}
+
+const (
+	imp     = "MOV 0, 1\n"
+	dwarf   = "ORG start\nbomb DAT #0, #0\nstart ADD #4, bomb\nMOV bomb, @bomb\nJMP start\n"
+	suicide = "DAT 0\n"
+	sitter  = "JMP 0\n"
+
+	// first kills whoever sits 40 cells away unless it gets killed first
+	first = "MOV 2, 40\nJMP 0\nDAT 0\n"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		warriors  []string
+		placement mars.Placement
+		rounds    int
+		want      []Score
+	}{
+		{[]string{imp, suicide}, nil, 4, []Score{{Wins: 4}, {Losses: 4}}},
+		{[]string{suicide, imp}, nil, 4, []Score{{Losses: 4}, {Wins: 4}}},
+		{[]string{sitter, sitter}, nil, 3, []Score{{Ties: 3}, {Ties: 3}}},
+		{[]string{sitter, sitter, suicide}, nil, 2, []Score{{Ties: 2}, {Ties: 2}, {Losses: 2}}},
+		{[]string{first, first}, mars.FixedPlacement{0, 40}, 4, []Score{{Wins: 2, Losses: 2}, {Wins: 2, Losses: 2}}},
+		{[]string{first, first}, mars.FixedPlacement{0, 40}, 3, []Score{{Wins: 2, Losses: 1}, {Wins: 1, Losses: 2}}},
+		{[]string{imp}, nil, 2, []Score{{Wins: 2}}},
+		{[]string{suicide}, nil, 2, []Score{{Losses: 2}}},
+	}
+
+	for i, test := range tests {
+		cfg := smallConfig(test.rounds)
+		cfg.Placement = test.placement
+
+		ws := []*assembler.Warrior{}
+		for _, w := range test.warriors {
+			ws = append(ws, assemble(t, "matchTest", w))
+		}
+
+		r, err := Run(cfg, ws)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if r.Rounds != test.rounds {
+			t.Errorf("test %d: got %d rounds, want %d", i, r.Rounds, test.rounds)
+		}
+		for j := range test.want {
+			test.want[j].Name = "matchTest"
+		}
+		if !reflect.DeepEqual(r.Scores, test.want) {
+			t.Errorf("test %d: got scores %+v, want %+v", i, r.Scores, test.want)
+		}
+	}
+}
+
+func TestRunDeterministic(t *testing.T) {
+	ws := []*assembler.Warrior{assemble(t, "dwarf", dwarf), assemble(t, "imp", imp), assemble(t, "sitter", sitter)}
+	run := func(seed uint64) []Score {
+		cfg := smallConfig(50)
+		cfg.Seed = seed
+		r, err := Run(cfg, ws)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return r.Scores
+	}
+
+	for seed := range uint64(10) {
+		if a, b := run(seed), run(seed); !reflect.DeepEqual(a, b) {
+			t.Errorf("seed %d: got %+v and then %+v", seed, a, b)
+		}
+	}
+}
+
+func TestPoints(t *testing.T) {
+	if got := (Score{Wins: 12, Ties: 5, Losses: 3}).Points(); got != 41 {
+		t.Errorf("got %d points, want 41", got)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	ws := []*assembler.Warrior{assemble(t, "imp", imp), assemble(t, "dwarf", dwarf)}
+
+	if _, err := Run(smallConfig(0), ws); !errors.Is(err, mars.ErrBadConfig) {
+		t.Errorf("got %v, want %v", err, mars.ErrBadConfig)
+	}
+
+	cfg := smallConfig(1)
+	cfg.Placement = mars.FixedPlacement{0, 3}
+	if _, err := Run(cfg, ws); !errors.Is(err, mars.ErrTooClose) {
+		t.Errorf("got %v, want %v", err, mars.ErrTooClose)
+	}
+}
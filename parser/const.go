@@ -5,6 +5,7 @@ import "fmt"
 type Opcode int
 type OpcodeModifier int
 type AddressingMode int
+type Operator int
 
 func (o Opcode) String() string {
 	for k, v := range opcodes {
@@ -23,6 +24,16 @@ func NewOpcode(s string) (Opcode, error) {
 	return o, nil
 }
 
+// IsPseudo reports whether o is a pseudo-opcode. These direct the
+// assembler rather than ending up in the core.
+func (o Opcode) IsPseudo() bool {
+	switch o {
+	case ORG, EQU, END, PIN:
+		return true
+	}
+	return false
+}
+
 func (o OpcodeModifier) String() string {
 	for k, v := range opcodeModifiers {
 		if v == o {
@@ -57,6 +68,23 @@ func NewAddressingMode(s string) (AddressingMode, error) {
 	return a, nil
 }
 
+func (o Operator) String() string {
+	for k, v := range operators {
+		if v == o {
+			return k
+		}
+	}
+	return "INVALID"
+}
+
+func NewOperator(s string) (Operator, error) {
+	o, ok := operators[s]
+	if !ok {
+		return -1, fmt.Errorf("wrong operator %q", s)
+	}
+	return o, nil
+}
+
 const (
 	// instruction opcodes
 	OPCODE_INVALID Opcode = iota // make Instruction{} invalid
@@ -74,9 +102,15 @@ const (
 	CMP
 	SLT
 	SPL
+	SEQ
+	SNE
+	NOP
+	LDP
+	STP
 	ORG
 	EQU
 	END
+	PIN
 )
 
 const (
@@ -99,6 +133,19 @@ const (
 	At
 	Lt
 	Gt
+	Asterisk
+	LBrace
+	RBrace
+)
+
+const (
+	// expression operators
+	OPERATOR_INVALID Operator = iota // make Expr{} a bare term
+	Plus
+	Minus
+	Star
+	Slash
+	Percent
 )
 
 var opcodes = map[string]Opcode{
@@ -116,9 +163,15 @@ var opcodes = map[string]Opcode{
 	"CMP": CMP,
 	"SLT": SLT,
 	"SPL": SPL,
+	"SEQ": SEQ,
+	"SNE": SNE,
+	"NOP": NOP,
+	"LDP": LDP,
+	"STP": STP,
 	"ORG": ORG,
 	"EQU": EQU,
 	"END": END,
+	"PIN": PIN,
 }
 
 var opcodeModifiers = map[string]OpcodeModifier{
@@ -137,4 +190,15 @@ var addressingModes = map[string]AddressingMode{
 	"@": At,
 	"<": Lt,
 	">": Gt,
+	"*": Asterisk,
+	"{": LBrace,
+	"}": RBrace,
+}
+
+var operators = map[string]Operator{
+	"+": Plus,
+	"-": Minus,
+	"*": Star,
+	"/": Slash,
+	"%": Percent,
 }
@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/pcolladosoto/corewarg/lexer"
+)
+
+// ErrDivisionByZero is returned when evaluating an expression divides by 0.
+var ErrDivisionByZero = errors.New("division by zero")
+
+// newBinaryExpr builds an expression applying op to x and y. Note the parser's
+// stack gets reused, so we must hold on to copies of the operands.
+func newBinaryExpr(op Operator, x, y Expr) Expr {
+	return Expr{Op: op, X: &x, Y: &y}
+}
+
+// newUnaryExpr builds an expression applying op to x.
+func newUnaryExpr(op Operator, x Expr) Expr {
+	return Expr{Op: op, X: &x}
+}
+
+// newParenTerm wraps an expression between parentheses into a term. Its
+// span covers the parentheses too.
+func newParenTerm(e Expr, span lexer.Span) Term {
+	return Term{Expr: &e, Span: span}
+}
+
+// IsUnary reports whether e applies its operator to a single operand.
+func (e Expr) IsUnary() bool {
+	return e.Op != OPERATOR_INVALID && e.Y == nil
+}
+
+// Eval computes the value of the expression. Labels are turned into numbers
+// through resolve. Division truncates towards zero and '%' yields the
+// remainder of said division, just like in C.
+func (e Expr) Eval(resolve func(Label) (int, error)) (int, error) {
+	if e.Op == OPERATOR_INVALID {
+		return e.Term.Eval(resolve)
+	}
+
+	x, err := e.X.Eval(resolve)
+	if err != nil {
+		return 0, err
+	}
+
+	if e.IsUnary() {
+		switch e.Op {
+		case Plus:
+			return x, nil
+		case Minus:
+			return -x, nil
+		}
+		return 0, fmt.Errorf("wrong unary operator %s", e.Op)
+	}
+
+	y, err := e.Y.Eval(resolve)
+	if err != nil {
+		return 0, err
+	}
+
+	switch e.Op {
+	case Plus:
+		return x + y, nil
+	case Minus:
+		return x - y, nil
+	case Star:
+		return x * y, nil
+	case Slash:
+		if y == 0 {
+			return 0, ErrDivisionByZero
+		}
+		return x / y, nil
+	case Percent:
+		if y == 0 {
+			return 0, ErrDivisionByZero
+		}
+		return x % y, nil
+	}
+	return 0, fmt.Errorf("wrong binary operator %s", e.Op)
+}
+
+// Eval computes the value of the term.
+func (t Term) Eval(resolve func(Label) (int, error)) (int, error) {
+	switch {
+	case t.Expr != nil:
+		return t.Expr.Eval(resolve)
+	case t.Label != "":
+		return resolve(t.Label)
+	}
+	return t.Immediate, nil
+}
+
+func (e Expr) String() string {
+	switch {
+	case e.Op == OPERATOR_INVALID:
+		return e.Term.String()
+	case e.IsUnary():
+		return fmt.Sprintf("%s%s", e.Op, e.X)
+	}
+	return fmt.Sprintf("%s%s%s", e.X, e.Op, e.Y)
+}
+
+func (t Term) String() string {
+	switch {
+	case t.Expr != nil:
+		return fmt.Sprintf("(%s)", t.Expr)
+	case t.Label != "":
+		return string(t.Label)
+	}
+	return fmt.Sprintf("%d", t.Immediate)
+}
+
+func (e Expr) MarshalJSON() ([]byte, error) {
+	if e.Op == OPERATOR_INVALID {
+		return json.Marshal(e.Term)
+	}
+	return json.Marshal(struct {
+		Op string `json:"op"`
+		X  *Expr  `json:"x"`
+		Y  *Expr  `json:"y,omitempty"`
+	}{Op: e.Op.String(), X: e.X, Y: e.Y})
+}
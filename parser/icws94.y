@@ -11,7 +11,9 @@ package parser
 
 import (
 	"strconv"
+	"strings"
 
+	"github.com/pcolladosoto/corewarg/diag"
 	"github.com/pcolladosoto/corewarg/lexer"
 )
 
@@ -24,14 +26,27 @@ type Operation struct {
 	Modifier OpcodeModifier
 }
 
+// Term is a leaf of an expression: either a label, a number or
+// a whole expression between parentheses.
 type Term struct {
 	Label Label
 	Immediate int
+	Expr *Expr `json:",omitempty"`
+	Span lexer.Span
+}
+
+// Expr is a node in an operand's expression tree. A bare term has
+// no operator. Unary operators only make use of X.
+type Expr struct {
+	Op Operator
+	X, Y *Expr
+	Term Term
 }
 
 type Operand struct {
 	Mode AddressingMode
-	Expr Term
+	Expr Expr
+	Span lexer.Span // from the mode, if any, to the end of the expression
 }
 
 type Instruction struct {
@@ -39,19 +54,21 @@ type Instruction struct {
 	Operation Operation
 	Operands []Operand
 	Comment Comment
+	Line int
+	Span lexer.Span // from the first label to the last operand, comments aside
 }
 
-var programAST []Instruction
-
 %}
 
 // Declare the type for values in the stack as well as available
 // tag names to declare token and non-terminal types.
 %union {
+	Span lexer.Span
 	Num int
 	Label Label
 	Operation Operation
 	Term Term
+	Expr Expr
 	LabelList []Label
 	Comment Comment
 	Instruction Instruction
@@ -63,7 +80,9 @@ var programAST []Instruction
 
 %type <Operation> operation
 %type <AddressingMode> mode
-%type <Term> expr
+%type <Expr> expr
+%type <Expr> mul_expr
+%type <Expr> unary_expr
 %type <Term> term
 %type <LabelList> label_list
 %type <Instruction> comment
@@ -85,11 +104,12 @@ var programAST []Instruction
 %token <AddressingMode>   ADDRESSING_MODE 7
 %token <Num>              NUMBER          8
 %token <Num>              OPERAND         9
+%token <Num>              COMMA           10
 
 // These tokens aren't defined in the lexer; they're implicitly created by Lex() (see below)
 // based on the lexer.Item.val of incoming lexer.ItemOperand tokens. That way we don't have
 // to mess around with so many tokens on the lexer where they don't really have any meaning.
-%token '+' '-' '*' '/' '(' ')'
+%token '+' '-' '*' '/' '%' '(' ')'
 
 // End the declarations
 %%
@@ -113,25 +133,22 @@ assembly_file:
 		logger.Debug("redn' at assembly_file", "LIST", $1);
 		$$ = $1;
 
-		programAST = $1
-
 		// Reverse the AST as the parser is a bottom up one!
-		for i := len(programAST)/2-1; i >= 0; i-- {
-			opp := len(programAST)-1-i
-			programAST[i], programAST[opp] = programAST[opp], programAST[i]
+		for i := len($$)/2-1; i >= 0; i-- {
+			opp := len($$)-1-i
+			$$[i], $$[opp] = $$[opp], $$[i]
 		}
+
+		// Hand the AST back through the lexer so that each parse keeps its own
+		// result. That's what makes the parser reentrant!
+		corewarlex.(*corewarLex).program = $$
 	}
 
+// The list can be empty: nothing may be left once EQUs are taken out.
 list:
-	  line {
-		logger.Debug("redn' at list", "LINE", $1);
-
-		// prevent comments from making it into the AST
-		if $1.Operation.Opcode != OPCODE_INVALID {
-			$$ = []Instruction{$1}
-		} else {
-			$$ = nil
-		}
+	  /* empty */ {
+		logger.Debug("redn' at list");
+		$$ = nil
 	}
 	| line list {
 		logger.Debug("redn' at list", "LINE", $1, "LIST", $2)
@@ -144,40 +161,44 @@ list:
 		}
 	}
 
+// On a syntax error we drop everything up to the end of the line and carry
+// on with the next one, which is how each broken line gets reported. The
+// error has already been recorded by then, so the line is just left out.
 line:
 	  instruction {logger.Debug("redn' at line", "INSTRUCTION", $1); $$ = $1}
 	| comment     {logger.Debug("redn' at line", "COMMENT", $1)}
+	| error EOL   {logger.Debug("redn' at line", "ERROR", $2); $$ = Instruction{}; Errflag = 0}
 
 comment:
 	  COMMENT EOL {logger.Debug("redn' at comment", "COMMENT", $1, "EOL", $2); $$ = Instruction{Comment: $1}}
 	| EOL         {logger.Debug("redn' at comment", "EOL", $1); $$ = Instruction{}}
 
 instruction:
-	  label_list operation mode expr           comment {
+	  label_list operation mode expr                 comment {
 		logger.Debug("redn' at instruction", "LABEL_LIST", $1, "OPERATION", $2, "MODE", $3, "EXPR", $4, "COMMENT", $5);
-		$$ = Instruction{Labels: $1, Operation: $2, Operands: []Operand{{Mode: $3, Expr: $4}}}
+		$$ = newInstruction($1, $<Span>1, $2, $<Span>2, newOperand($3, $<Span>3, $4, $<Span>4))
 	}
-	|            operation mode expr           comment {
+	|            operation mode expr                 comment {
 		logger.Debug("redn' at instruction", "OPERATION", $1, "MODE", $2, "EXPR", $3, "COMMENT", $4);
-		$$ = Instruction{Labels: nil, Operation: $1, Operands: []Operand{{Mode: $2, Expr: $3}}}
+		$$ = newInstruction(nil, $<Span>1, $1, $<Span>1, newOperand($2, $<Span>2, $3, $<Span>3))
 	}
-	| label_list operation mode expr mode expr comment {
-		logger.Debug("redn' at instruction", "LABEL_LIST", $1, "OPERATION", $2, "MODE", $3, "EXPR", $4, "MODE", $5, "EXPR", $6, "COMMENT", $7);
-		$$ = Instruction{Labels: $1, Operation: $2, Operands: []Operand{{Mode: $3, Expr: $4}, {Mode: $5, Expr: $6}}}
+	| label_list operation mode expr COMMA mode expr comment {
+		logger.Debug("redn' at instruction", "LABEL_LIST", $1, "OPERATION", $2, "MODE", $3, "EXPR", $4, "MODE", $6, "EXPR", $7, "COMMENT", $8);
+		$$ = newInstruction($1, $<Span>1, $2, $<Span>2, newOperand($3, $<Span>3, $4, $<Span>4), newOperand($6, $<Span>6, $7, $<Span>7))
 	}
-	|            operation mode expr mode expr comment {
-		logger.Debug("redn' at instruction", "OPERATION", $1, "MODE", $2, "EXPR", $3, "MODE", $4, "EXPR", $5, "COMMENT", $6);
-		$$ = Instruction{Labels: nil, Operation: $1, Operands: []Operand{{Mode: $2, Expr: $3}, {Mode: $4, Expr: $5}}}
+	|            operation mode expr COMMA mode expr comment {
+		logger.Debug("redn' at instruction", "OPERATION", $1, "MODE", $2, "EXPR", $3, "MODE", $5, "EXPR", $6, "COMMENT", $7);
+		$$ = newInstruction(nil, $<Span>1, $1, $<Span>1, newOperand($2, $<Span>2, $3, $<Span>3), newOperand($5, $<Span>5, $6, $<Span>6))
 	}
 	// Special case for END
 	| label_list operation comment {
 		logger.Debug("redn' at instruction","LABEL_LIST", $1, "OPERATION", $2, "COMMENT", $3);
-		$$ = Instruction{Labels: $1, Operation: $2, Operands: nil}
+		$$ = newInstruction($1, $<Span>1, $2, $<Span>2)
 	}
 	// Special case for END
 	|            operation comment {
 		logger.Debug("redn' at instruction", "OPERATION", $1, "COMMENT", $2);
-		$$ = Instruction{Labels: nil, Operation: $1, Operands: nil}
+		$$ = newInstruction(nil, $<Span>1, $1, $<Span>1)
 	}
 
 label_list:
@@ -187,65 +208,36 @@ label_list:
 
 operation:
 	  OPCODE                 {logger.Debug("redn' at operation", "OPCODE", $1)                       ; $$ = Operation{$1, OPCODE_MODIFIER_INVALID}}
-	| OPCODE OPCODE_MODIFIER {logger.Debug("redn' at operation", "OPCODE", $1, "OPCODE_MODIFIER", $2); $$ = Operation{$1, $2}}
+	| OPCODE OPCODE_MODIFIER {logger.Debug("redn' at operation", "OPCODE", $1, "OPCODE_MODIFIER", $2); $$ = Operation{$1, $2}; $<Span>$ = $<Span>1.Join($<Span>2)}
 
 mode:
 	  ADDRESSING_MODE {logger.Debug("redn' at mode", "ADDRESSING_MODE", $1);      $$ = $1}
-	| /* empty */     {logger.Debug("redn' at mode", "ADDRESSING_MODE", "EMPTY"); $$ = ADDRESSING_MODE_INVALID}
+	| /* empty */     {logger.Debug("redn' at mode", "ADDRESSING_MODE", "EMPTY"); $$ = ADDRESSING_MODE_INVALID; $<Span>$ = lexer.Span{}}
 
+// Expressions are split in several levels so that precedence comes
+// straight out of the grammar: unary operators bind the tightest, then
+// come '*', '/' and '%' and finally '+' and '-'. Every binary operator
+// is left associative. Note '%' yields the remainder of integer division!
 expr:
-	term {logger.Debug("reduction at expr", "TERM", $1); $$ = $1}
-
-term:
-	  LABEL  {logger.Debug("redn' at term",  "LABEL", $1); $$ = Term{Label: $1, Immediate: 0}}
-	| NUMBER {logger.Debug("redn' at term", "NUMBER", $1); $$ = Term{Label: "", Immediate: $1}}
+	  mul_expr          {logger.Debug("redn' at expr", "MUL_EXPR", $1); $$ = $1}
+	| expr '+' mul_expr {logger.Debug("redn' at expr", "EXPR", $1, "MUL_EXPR", $3); $$ = newBinaryExpr(Plus, $1, $3); $<Span>$ = $<Span>1.Join($<Span>3)}
+	| expr '-' mul_expr {logger.Debug("redn' at expr", "EXPR", $1, "MUL_EXPR", $3); $$ = newBinaryExpr(Minus, $1, $3); $<Span>$ = $<Span>1.Join($<Span>3)}
 
+mul_expr:
+	  unary_expr              {logger.Debug("redn' at mul_expr", "UNARY_EXPR", $1); $$ = $1}
+	| mul_expr '*' unary_expr {logger.Debug("redn' at mul_expr", "MUL_EXPR", $1, "UNARY_EXPR", $3); $$ = newBinaryExpr(Star, $1, $3); $<Span>$ = $<Span>1.Join($<Span>3)}
+	| mul_expr '/' unary_expr {logger.Debug("redn' at mul_expr", "MUL_EXPR", $1, "UNARY_EXPR", $3); $$ = newBinaryExpr(Slash, $1, $3); $<Span>$ = $<Span>1.Join($<Span>3)}
+	| mul_expr '%' unary_expr {logger.Debug("redn' at mul_expr", "MUL_EXPR", $1, "UNARY_EXPR", $3); $$ = newBinaryExpr(Percent, $1, $3); $<Span>$ = $<Span>1.Join($<Span>3)}
 
-// NOTE: % returns the remainder of integer division!
+unary_expr:
+	  term           {logger.Debug("redn' at unary_expr", "TERM", $1); $$ = Expr{Term: $1}}
+	| '+' unary_expr {logger.Debug("redn' at unary_expr", "UNARY_EXPR", $2); $$ = newUnaryExpr(Plus, $2); $<Span>$ = $<Span>1.Join($<Span>2)}
+	| '-' unary_expr {logger.Debug("redn' at unary_expr", "UNARY_EXPR", $2); $$ = newUnaryExpr(Minus, $2); $<Span>$ = $<Span>1.Join($<Span>2)}
 
-top:
-	expr
-	{
-		if $1.IsInt() {
-			fmt.Println($1.Num().String())
-		} else {
-			fmt.Println($1.String())
-		}
-	}
-
-expr:
-	  expr1
-	| '+' expr {$$ = $2}
-	| '-' expr {$$ = $2.Neg($2)}
-
-expr1:
-	  expr2
-	| expr1 '+' expr2 {$$ = $1.Add($1, $3)}
-	| expr1 '-' expr2 {$$ = $1.Sub($1, $3)}
-
-expr2:
-	  expr3
-	| expr2 '*' expr3 {$$ = $1.Mul($1, $3)}
-	| expr2 '/' expr3 {$$ = $1.Quo($1, $3)}
-
-expr3:
-	  NUM
-	| '(' expr ')' {$$ = $2}
-
-// Or...
-%left '+'
-%left '*'
-%%
-
-/*** Rules Section ***/
-start : expr '\n'		{exit(1);}
-      ;
-
-expr: expr '+' expr     {print_operator('+');}
-    | expr '*' expr     {print_operator('*');}
-    | '(' expr ')'
-    | DIGIT             {printf("NUM%d ",pos);}
-    ;
+term:
+	  LABEL        {logger.Debug("redn' at term",  "LABEL", $1); $$ = Term{Label: $1, Immediate: 0, Span: $<Span>1}}
+	| NUMBER       {logger.Debug("redn' at term", "NUMBER", $1); $$ = Term{Label: "", Immediate: $1, Span: $<Span>1}}
+	| '(' expr ')' {logger.Debug("redn' at term", "EXPR", $2);   $<Span>$ = $<Span>1.Join($<Span>3); $$ = newParenTerm($2, $<Span>$)}
 
 %%
 
@@ -260,7 +252,17 @@ expr: expr '+' expr     {print_operator('+');}
 // Note the prefix (i.e. coreWar) is provided to goyacc
 // through the -p flag.
 type corewarLex struct {
-	l *lexer.Lexer
+	name    string
+	l       itemSource
+	program []Instruction
+	diags   diag.List
+	pos     lexer.Pos // where the last item begins
+}
+
+// itemSource is where corewarLex gets its items from: be it
+// the lexer itself or something sitting in between.
+type itemSource interface {
+	NextItem() lexer.Item
 }
 
 // Lex should return a new token. It's called by the parser. One
@@ -268,23 +270,32 @@ type corewarLex struct {
 // the exprSymType.
 func (x *corewarLex) Lex(yylval *corewarSymType) int {
 	ni := x.l.NextItem()
-	logger.Debug("got item", "typ", ni.Typ, "val", ni.Val)
-
+	logger.Debug("got item", "typ", ni.Typ, "val", ni.Val, "pos", ni.Pos)
+
+	// Every token carries its span so that nonterminals built out of
+	// them (e.g. operation) remember where they come from. Nonterminals
+	// start off with the value of their first symbol, so productions only
+	// have to stretch it up to their last one.
+	yylval.Span = ni.Span()
+	x.pos = ni.Pos
+
+	// Items the lexer vouched for but we still can't make sense of are
+	// reported and handed over as they are: the grammar has no room for
+	// them, so the parser drops their line as it would any syntax error.
 	var err error
 	switch ni.Typ {
 	case lexer.ItemOperand:
-			runes := []rune(ni.Val)
-
-			if len(runes) != 1 { // should be the case, but who knows...
-				logger.Error("wrong value for ItemOperand", "ni.Val", ni.Val)
-				return -1 // Will this work?
-			}
+		runes := []rune(ni.Val)
+		if len(runes) != 1 { // should be the case, but who knows...
+			x.diags.Errorf(ni.Pos, diag.BadToken, "wrong value for operand %q", ni.Val)
+			break
+		}
+		return int(runes[0])
 
-			return int(runes[0])
 	case lexer.ItemNumber:
 		pInt, err := strconv.ParseInt(ni.Val, 10, 32)
 		if err != nil {
-			logger.Error("error parsing number %q: %v\n", ni.Val, err)
+			x.diags.Errorf(ni.Pos, diag.BadToken, "error parsing number %q: %w", ni.Val, err)
 		}
 		yylval.Num = int(pInt)
 
@@ -295,24 +306,21 @@ func (x *corewarLex) Lex(yylval *corewarSymType) int {
 		yylval.Comment = Comment(ni.Val)
 
 	case lexer.ItemOpcode:
-		yylval.Opcode, err = NewOpcode(ni.Val)
+		yylval.Opcode, err = NewOpcode(strings.ToUpper(ni.Val))
 		if err != nil {
-			logger.Error("error processing opcode", "err", err)
-			return -1 // Will this work?
+			x.diags.Errorf(ni.Pos, diag.BadToken, "error processing opcode: %w", err)
 		}
 
 	case lexer.ItemOpcodeModifier:
-		yylval.OpcodeModifier, err = NewOpcodeModifier(ni.Val)
+		yylval.OpcodeModifier, err = NewOpcodeModifier(strings.ToUpper(ni.Val))
 		if err != nil {
-			logger.Error("error processing opcode modifier", "err", err)
-			return -1 // Will this work?
+			x.diags.Errorf(ni.Pos, diag.BadToken, "error processing opcode modifier: %w", err)
 		}
 
 	case lexer.ItemAddressingMode:
 		yylval.AddressingMode, err = NewAddressingMode(ni.Val)
 		if err != nil {
-			logger.Error("error processing addressing mode", "err", err)
-			return -1 // Will this work?
+			x.diags.Errorf(ni.Pos, diag.BadToken, "error processing addressing mode: %w", err)
 		}
 
 	case lexer.ItemEOF:
@@ -324,6 +332,9 @@ func (x *corewarLex) Lex(yylval *corewarSymType) int {
 	return int(ni.Typ)
 }
 
+// Error is called by the parser on syntax errors, which are reported at
+// the last item it got.
 func (x *corewarLex) Error(s string) {
-	logger.Error("parse error", "err", s)
+	logger.Debug("parse error", "pos", x.pos, "err", s)
+	x.diags.Errorf(x.pos, diag.Syntax, "%s", s)
 }
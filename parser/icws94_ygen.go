@@ -0,0 +1,910 @@
+// Code generated by goyacc -o icws94_ygen.go -p corewar icws94.y. DO NOT EDIT.
+
+//line icws94.y:9
+
+package parser
+
+import __yyfmt__ "fmt"
+
+//line icws94.y:10
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pcolladosoto/corewarg/diag"
+	"github.com/pcolladosoto/corewarg/lexer"
+)
+
+type Comment string
+
+type Label string
+
+type Operation struct {
+	Opcode   Opcode
+	Modifier OpcodeModifier
+}
+
+// Term is a leaf of an expression: either a label, a number or
+// a whole expression between parentheses.
+type Term struct {
+	Label     Label
+	Immediate int
+	Expr      *Expr `json:",omitempty"`
+	Span      lexer.Span
+}
+
+// Expr is a node in an operand's expression tree. A bare term has
+// no operator. Unary operators only make use of X.
+type Expr struct {
+	Op   Operator
+	X, Y *Expr
+	Term Term
+}
+
+type Operand struct {
+	Mode AddressingMode
+	Expr Expr
+	Span lexer.Span // from the mode, if any, to the end of the expression
+}
+
+type Instruction struct {
+	Labels    []Label
+	Operation Operation
+	Operands  []Operand
+	Comment   Comment
+	Line      int
+	Span      lexer.Span // from the first label to the last operand, comments aside
+}
+
+//line icws94.y:65
+type corewarSymType struct {
+	yys            int
+	Span           lexer.Span
+	Num            int
+	Label          Label
+	Operation      Operation
+	Term           Term
+	Expr           Expr
+	LabelList      []Label
+	Comment        Comment
+	Instruction    Instruction
+	List           []Instruction
+	AddressingMode AddressingMode
+	Opcode         Opcode
+	OpcodeModifier OpcodeModifier
+}
+
+const EOL = 2
+const COMMENT = 3
+const LABEL = 4
+const OPCODE = 5
+const OPCODE_MODIFIER = 6
+const ADDRESSING_MODE = 7
+const NUMBER = 8
+const OPERAND = 9
+const COMMA = 10
+
+var corewarToknames = [...]string{
+	"$end",
+	"error",
+	"$unk",
+	"EOL",
+	"COMMENT",
+	"LABEL",
+	"OPCODE",
+	"OPCODE_MODIFIER",
+	"ADDRESSING_MODE",
+	"NUMBER",
+	"OPERAND",
+	"COMMA",
+	"'+'",
+	"'-'",
+	"'*'",
+	"'/'",
+	"'%'",
+	"'('",
+	"')'",
+}
+
+var corewarStatenames = [...]string{}
+
+const corewarEofCode = 1
+const corewarErrCode = 2
+const corewarInitialStackSize = 16
+
+//line icws94.y:242
+
+// This struct should adhere to the corewarLexer interface:
+//
+//	type corewarLexer interface {
+//		Lex(lval *exprSymType) int
+//		Error(s string)
+//	}
+//
+// The interface definition is generated by GoYacc!
+// Note the prefix (i.e. coreWar) is provided to goyacc
+// through the -p flag.
+type corewarLex struct {
+	name    string
+	l       itemSource
+	program []Instruction
+	diags   diag.List
+	pos     lexer.Pos // where the last item begins
+}
+
+// itemSource is where corewarLex gets its items from: be it
+// the lexer itself or something sitting in between.
+type itemSource interface {
+	NextItem() lexer.Item
+}
+
+// Lex should return a new token. It's called by the parser. One
+// can set the returned token's value through the reference to
+// the exprSymType.
+func (x *corewarLex) Lex(yylval *corewarSymType) int {
+	ni := x.l.NextItem()
+	logger.Debug("got item", "typ", ni.Typ, "val", ni.Val, "pos", ni.Pos)
+
+	// Every token carries its span so that nonterminals built out of
+	// them (e.g. operation) remember where they come from. Nonterminals
+	// start off with the value of their first symbol, so productions only
+	// have to stretch it up to their last one.
+	yylval.Span = ni.Span()
+	x.pos = ni.Pos
+
+	// Items the lexer vouched for but we still can't make sense of are
+	// reported and handed over as they are: the grammar has no room for
+	// them, so the parser drops their line as it would any syntax error.
+	var err error
+	switch ni.Typ {
+	case lexer.ItemOperand:
+		runes := []rune(ni.Val)
+		if len(runes) != 1 { // should be the case, but who knows...
+			x.diags.Errorf(ni.Pos, diag.BadToken, "wrong value for operand %q", ni.Val)
+			break
+		}
+		return int(runes[0])
+
+	case lexer.ItemNumber:
+		pInt, err := strconv.ParseInt(ni.Val, 10, 32)
+		if err != nil {
+			x.diags.Errorf(ni.Pos, diag.BadToken, "error parsing number %q: %w", ni.Val, err)
+		}
+		yylval.Num = int(pInt)
+
+	case lexer.ItemLabel:
+		yylval.Label = Label(ni.Val)
+
+	case lexer.ItemComment:
+		yylval.Comment = Comment(ni.Val)
+
+	case lexer.ItemOpcode:
+		yylval.Opcode, err = NewOpcode(strings.ToUpper(ni.Val))
+		if err != nil {
+			x.diags.Errorf(ni.Pos, diag.BadToken, "error processing opcode: %w", err)
+		}
+
+	case lexer.ItemOpcodeModifier:
+		yylval.OpcodeModifier, err = NewOpcodeModifier(strings.ToUpper(ni.Val))
+		if err != nil {
+			x.diags.Errorf(ni.Pos, diag.BadToken, "error processing opcode modifier: %w", err)
+		}
+
+	case lexer.ItemAddressingMode:
+		yylval.AddressingMode, err = NewAddressingMode(ni.Val)
+		if err != nil {
+			x.diags.Errorf(ni.Pos, diag.BadToken, "error processing addressing mode: %w", err)
+		}
+
+	case lexer.ItemEOF:
+		return 0 // GoYacc expects EOF to be 0
+
+	default:
+		yylval.Num = int(ni.Typ)
+	}
+	return int(ni.Typ)
+}
+
+// Error is called by the parser on syntax errors, which are reported at
+// the last item it got.
+func (x *corewarLex) Error(s string) {
+	logger.Debug("parse error", "pos", x.pos, "err", s)
+	x.diags.Errorf(x.pos, diag.Syntax, "%s", s)
+}
+
+//line yacctab:1
+var corewarExca = [...]int8{
+	-1, 0,
+	1, 2,
+	-2, 0,
+	-1, 1,
+	1, -1,
+	-2, 0,
+	-1, 3,
+	1, 2,
+	-2, 0,
+}
+
+const corewarPrivate = 57344
+
+const corewarLast = 83
+
+var corewarAct = [...]int8{
+	5, 16, 27, 25, 38, 39, 7, 26, 18, 17,
+	54, 40, 41, 42, 10, 9, 24, 23, 20, 22,
+	10, 9, 47, 38, 39, 12, 36, 35, 34, 38,
+	39, 11, 43, 44, 8, 19, 46, 45, 21, 48,
+	11, 14, 15, 51, 52, 53, 49, 50, 1, 55,
+	10, 9, 56, 3, 4, 18, 31, 58, 59, 57,
+	32, 10, 9, 29, 30, 28, 0, 0, 33, 37,
+	38, 39, 6, 0, 10, 9, 11, 12, 2, 0,
+	0, 0, 13,
+}
+
+var corewarPact = [...]int16{
+	70, -1000, -1000, 70, -1000, -1000, 37, 18, 46, 31,
+	-1000, 34, 11, -1000, -1000, 46, 50, -1000, -1000, -1000,
+	-1000, 25, -1000, 50, -1000, 57, -4, -1000, -1000, 50,
+	50, -1000, -1000, 50, -1000, 10, -1000, -1, 50, 50,
+	50, 50, 50, -1000, -1000, -9, -1000, -1, 50, -4,
+	-4, -1000, -1000, -1000, -1000, 50, 16, 16, -1000, -1000,
+}
+
+var corewarPgo = [...]int8{
+	0, 34, 1, 3, 7, 2, 65, 6, 0, 54,
+	53, 78, 48,
+}
+
+var corewarR1 = [...]int8{
+	0, 12, 11, 11, 10, 10, 10, 8, 8, 9,
+	9, 9, 9, 9, 9, 7, 7, 7, 1, 1,
+	2, 2, 3, 3, 3, 4, 4, 4, 4, 5,
+	5, 5, 6, 6, 6,
+}
+
+var corewarR2 = [...]int8{
+	0, 1, 0, 2, 1, 1, 2, 2, 1, 5,
+	4, 8, 7, 3, 2, 1, 2, 3, 1, 2,
+	1, 0, 1, 3, 3, 1, 3, 3, 3, 1,
+	2, 2, 1, 1, 3,
+}
+
+var corewarChk = [...]int16{
+	-1000, -12, -11, -10, -9, -8, 2, -7, -1, 5,
+	4, 6, 7, -11, 4, -1, -2, -8, 9, 4,
+	-7, 4, 8, -2, -8, -3, -4, -5, -6, 13,
+	14, 6, 10, 18, -7, -3, -8, 12, 13, 14,
+	15, 16, 17, -5, -5, -3, -8, 12, -2, -4,
+	-4, -5, -5, -5, 19, -2, -3, -3, -8, -8,
+}
+
+var corewarDef = [...]int8{
+	-2, -2, 1, -2, 4, 5, 0, 0, 21, 0,
+	8, 15, 18, 3, 6, 21, 0, 14, 20, 7,
+	16, 0, 19, 0, 13, 0, 22, 25, 29, 0,
+	0, 32, 33, 0, 17, 0, 10, 21, 0, 0,
+	0, 0, 0, 30, 31, 0, 9, 21, 0, 23,
+	24, 26, 27, 28, 34, 0, 0, 0, 12, 11,
+}
+
+var corewarTok1 = [...]int8{
+	1, 3, 4, 5, 6, 7, 8, 9, 10, 11,
+	12, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 17, 3, 3,
+	18, 19, 15, 13, 3, 14, 3, 16,
+}
+
+var corewarTok2 = [...]int8{
+	2, 3,
+}
+
+var corewarTok3 = [...]int8{
+	0,
+}
+
+var corewarErrorMessages = [...]struct {
+	state int
+	token int
+	msg   string
+}{}
+
+//line yaccpar:1
+
+/*	parser for yacc output	*/
+
+var (
+	corewarDebug        = 0
+	corewarErrorVerbose = false
+)
+
+type corewarLexer interface {
+	Lex(lval *corewarSymType) int
+	Error(s string)
+}
+
+type corewarParser interface {
+	Parse(corewarLexer) int
+	Lookahead() int
+}
+
+type corewarParserImpl struct {
+	lval  corewarSymType
+	stack [corewarInitialStackSize]corewarSymType
+	char  int
+}
+
+func (p *corewarParserImpl) Lookahead() int {
+	return p.char
+}
+
+func corewarNewParser() corewarParser {
+	return &corewarParserImpl{}
+}
+
+const corewarFlag = -1000
+
+func corewarTokname(c int) string {
+	if c >= 1 && c-1 < len(corewarToknames) {
+		if corewarToknames[c-1] != "" {
+			return corewarToknames[c-1]
+		}
+	}
+	return __yyfmt__.Sprintf("tok-%v", c)
+}
+
+func corewarStatname(s int) string {
+	if s >= 0 && s < len(corewarStatenames) {
+		if corewarStatenames[s] != "" {
+			return corewarStatenames[s]
+		}
+	}
+	return __yyfmt__.Sprintf("state-%v", s)
+}
+
+func corewarErrorMessage(state, lookAhead int) string {
+	const TOKSTART = 4
+
+	if !corewarErrorVerbose {
+		return "syntax error"
+	}
+
+	for _, e := range corewarErrorMessages {
+		if e.state == state && e.token == lookAhead {
+			return "syntax error: " + e.msg
+		}
+	}
+
+	res := "syntax error: unexpected " + corewarTokname(lookAhead)
+
+	// To match Bison, suggest at most four expected tokens.
+	expected := make([]int, 0, 4)
+
+	// Look for shiftable tokens.
+	base := int(corewarPact[state])
+	for tok := TOKSTART; tok-1 < len(corewarToknames); tok++ {
+		if n := base + tok; n >= 0 && n < corewarLast && int(corewarChk[int(corewarAct[n])]) == tok {
+			if len(expected) == cap(expected) {
+				return res
+			}
+			expected = append(expected, tok)
+		}
+	}
+
+	if corewarDef[state] == -2 {
+		i := 0
+		for corewarExca[i] != -1 || int(corewarExca[i+1]) != state {
+			i += 2
+		}
+
+		// Look for tokens that we accept or reduce.
+		for i += 2; corewarExca[i] >= 0; i += 2 {
+			tok := int(corewarExca[i])
+			if tok < TOKSTART || corewarExca[i+1] == 0 {
+				continue
+			}
+			if len(expected) == cap(expected) {
+				return res
+			}
+			expected = append(expected, tok)
+		}
+
+		// If the default action is to accept or reduce, give up.
+		if corewarExca[i+1] != 0 {
+			return res
+		}
+	}
+
+	for i, tok := range expected {
+		if i == 0 {
+			res += ", expecting "
+		} else {
+			res += " or "
+		}
+		res += corewarTokname(tok)
+	}
+	return res
+}
+
+func corewarlex1(lex corewarLexer, lval *corewarSymType) (char, token int) {
+	token = 0
+	char = lex.Lex(lval)
+	if char <= 0 {
+		token = int(corewarTok1[0])
+		goto out
+	}
+	if char < len(corewarTok1) {
+		token = int(corewarTok1[char])
+		goto out
+	}
+	if char >= corewarPrivate {
+		if char < corewarPrivate+len(corewarTok2) {
+			token = int(corewarTok2[char-corewarPrivate])
+			goto out
+		}
+	}
+	for i := 0; i < len(corewarTok3); i += 2 {
+		token = int(corewarTok3[i+0])
+		if token == char {
+			token = int(corewarTok3[i+1])
+			goto out
+		}
+	}
+
+out:
+	if token == 0 {
+		token = int(corewarTok2[1]) /* unknown char */
+	}
+	if corewarDebug >= 3 {
+		__yyfmt__.Printf("lex %s(%d)\n", corewarTokname(token), uint(char))
+	}
+	return char, token
+}
+
+func corewarParse(corewarlex corewarLexer) int {
+	return corewarNewParser().Parse(corewarlex)
+}
+
+func (corewarrcvr *corewarParserImpl) Parse(corewarlex corewarLexer) int {
+	var corewarn int
+	var corewarVAL corewarSymType
+	var corewarDollar []corewarSymType
+	_ = corewarDollar // silence set and not used
+	corewarS := corewarrcvr.stack[:]
+
+	Nerrs := 0   /* number of errors */
+	Errflag := 0 /* error recovery flag */
+	corewarstate := 0
+	corewarrcvr.char = -1
+	corewartoken := -1 // corewarrcvr.char translated into internal numbering
+	defer func() {
+		// Make sure we report no lookahead when not parsing.
+		corewarstate = -1
+		corewarrcvr.char = -1
+		corewartoken = -1
+	}()
+	corewarp := -1
+	goto corewarstack
+
+ret0:
+	return 0
+
+ret1:
+	return 1
+
+corewarstack:
+	/* put a state and value onto the stack */
+	if corewarDebug >= 4 {
+		__yyfmt__.Printf("char %v in %v\n", corewarTokname(corewartoken), corewarStatname(corewarstate))
+	}
+
+	corewarp++
+	if corewarp >= len(corewarS) {
+		nyys := make([]corewarSymType, len(corewarS)*2)
+		copy(nyys, corewarS)
+		corewarS = nyys
+	}
+	corewarS[corewarp] = corewarVAL
+	corewarS[corewarp].yys = corewarstate
+
+corewarnewstate:
+	corewarn = int(corewarPact[corewarstate])
+	if corewarn <= corewarFlag {
+		goto corewardefault /* simple state */
+	}
+	if corewarrcvr.char < 0 {
+		corewarrcvr.char, corewartoken = corewarlex1(corewarlex, &corewarrcvr.lval)
+	}
+	corewarn += corewartoken
+	if corewarn < 0 || corewarn >= corewarLast {
+		goto corewardefault
+	}
+	corewarn = int(corewarAct[corewarn])
+	if int(corewarChk[corewarn]) == corewartoken { /* valid shift */
+		corewarrcvr.char = -1
+		corewartoken = -1
+		corewarVAL = corewarrcvr.lval
+		corewarstate = corewarn
+		if Errflag > 0 {
+			Errflag--
+		}
+		goto corewarstack
+	}
+
+corewardefault:
+	/* default state action */
+	corewarn = int(corewarDef[corewarstate])
+	if corewarn == -2 {
+		if corewarrcvr.char < 0 {
+			corewarrcvr.char, corewartoken = corewarlex1(corewarlex, &corewarrcvr.lval)
+		}
+
+		/* look through exception table */
+		xi := 0
+		for {
+			if corewarExca[xi+0] == -1 && int(corewarExca[xi+1]) == corewarstate {
+				break
+			}
+			xi += 2
+		}
+		for xi += 2; ; xi += 2 {
+			corewarn = int(corewarExca[xi+0])
+			if corewarn < 0 || corewarn == corewartoken {
+				break
+			}
+		}
+		corewarn = int(corewarExca[xi+1])
+		if corewarn < 0 {
+			goto ret0
+		}
+	}
+	if corewarn == 0 {
+		/* error ... attempt to resume parsing */
+		switch Errflag {
+		case 0: /* brand new error */
+			corewarlex.Error(corewarErrorMessage(corewarstate, corewartoken))
+			Nerrs++
+			if corewarDebug >= 1 {
+				__yyfmt__.Printf("%s", corewarStatname(corewarstate))
+				__yyfmt__.Printf(" saw %s\n", corewarTokname(corewartoken))
+			}
+			fallthrough
+
+		case 1, 2: /* incompletely recovered error ... try again */
+			Errflag = 3
+
+			/* find a state where "error" is a legal shift action */
+			for corewarp >= 0 {
+				corewarn = int(corewarPact[corewarS[corewarp].yys]) + corewarErrCode
+				if corewarn >= 0 && corewarn < corewarLast {
+					corewarstate = int(corewarAct[corewarn]) /* simulate a shift of "error" */
+					if int(corewarChk[corewarstate]) == corewarErrCode {
+						goto corewarstack
+					}
+				}
+
+				/* the current p has no shift on "error", pop stack */
+				if corewarDebug >= 2 {
+					__yyfmt__.Printf("error recovery pops state %d\n", corewarS[corewarp].yys)
+				}
+				corewarp--
+			}
+			/* there is no state on the stack with an error shift ... abort */
+			goto ret1
+
+		case 3: /* no shift yet; clobber input char */
+			if corewarDebug >= 2 {
+				__yyfmt__.Printf("error recovery discards %s\n", corewarTokname(corewartoken))
+			}
+			if corewartoken == corewarEofCode {
+				goto ret1
+			}
+			corewarrcvr.char = -1
+			corewartoken = -1
+			goto corewarnewstate /* try again in the same state */
+		}
+	}
+
+	/* reduction by production corewarn */
+	if corewarDebug >= 2 {
+		__yyfmt__.Printf("reduce %v in:\n\t%v\n", corewarn, corewarStatname(corewarstate))
+	}
+
+	corewarnt := corewarn
+	corewarpt := corewarp
+	_ = corewarpt // guard against "declared and not used"
+
+	corewarp -= int(corewarR2[corewarn])
+	// corewarp is now the index of $0. Perform the default action. Iff the
+	// reduced production is ε, $1 is possibly out of range.
+	if corewarp+1 >= len(corewarS) {
+		nyys := make([]corewarSymType, len(corewarS)*2)
+		copy(nyys, corewarS)
+		corewarS = nyys
+	}
+	corewarVAL = corewarS[corewarp+1]
+
+	/* consult goto table to find next state */
+	corewarn = int(corewarR1[corewarn])
+	corewarg := int(corewarPgo[corewarn])
+	corewarj := corewarg + corewarS[corewarp].yys + 1
+
+	if corewarj >= corewarLast {
+		corewarstate = int(corewarAct[corewarg])
+	} else {
+		corewarstate = int(corewarAct[corewarj])
+		if int(corewarChk[corewarstate]) != -corewarn {
+			corewarstate = int(corewarAct[corewarg])
+		}
+	}
+	// dummy call; replaced with literal code
+	switch corewarnt {
+
+	case 1:
+		corewarDollar = corewarS[corewarpt-1 : corewarpt+1]
+//line icws94.y:132
+		{
+			logger.Debug("redn' at assembly_file", "LIST", corewarDollar[1].List)
+			corewarVAL.List = corewarDollar[1].List
+
+			// Reverse the AST as the parser is a bottom up one!
+			for i := len(corewarVAL.List)/2 - 1; i >= 0; i-- {
+				opp := len(corewarVAL.List) - 1 - i
+				corewarVAL.List[i], corewarVAL.List[opp] = corewarVAL.List[opp], corewarVAL.List[i]
+			}
+
+			// Hand the AST back through the lexer so that each parse keeps its own
+			// result. That's what makes the parser reentrant!
+			corewarlex.(*corewarLex).program = corewarVAL.List
+		}
+	case 2:
+		corewarDollar = corewarS[corewarpt-0 : corewarpt+1]
+//line icws94.y:149
+		{
+			logger.Debug("redn' at list")
+			corewarVAL.List = nil
+		}
+	case 3:
+		corewarDollar = corewarS[corewarpt-2 : corewarpt+1]
+//line icws94.y:153
+		{
+			logger.Debug("redn' at list", "LINE", corewarDollar[1].Instruction, "LIST", corewarDollar[2].List)
+
+			// prevent comments from making it into the AST
+			if corewarDollar[1].Instruction.Operation.Opcode != OPCODE_INVALID {
+				corewarVAL.List = append(corewarDollar[2].List, corewarDollar[1].Instruction)
+			} else {
+				corewarVAL.List = corewarDollar[2].List
+			}
+		}
+	case 4:
+		corewarDollar = corewarS[corewarpt-1 : corewarpt+1]
+//line icws94.y:168
+		{
+			logger.Debug("redn' at line", "INSTRUCTION", corewarDollar[1].Instruction)
+			corewarVAL.Instruction = corewarDollar[1].Instruction
+		}
+	case 5:
+		corewarDollar = corewarS[corewarpt-1 : corewarpt+1]
+//line icws94.y:169
+		{
+			logger.Debug("redn' at line", "COMMENT", corewarDollar[1].Instruction)
+		}
+	case 6:
+		corewarDollar = corewarS[corewarpt-2 : corewarpt+1]
+//line icws94.y:170
+		{
+			logger.Debug("redn' at line", "ERROR", corewarDollar[2].Num)
+			corewarVAL.Instruction = Instruction{}
+			Errflag = 0
+		}
+	case 7:
+		corewarDollar = corewarS[corewarpt-2 : corewarpt+1]
+//line icws94.y:173
+		{
+			logger.Debug("redn' at comment", "COMMENT", corewarDollar[1].Comment, "EOL", corewarDollar[2].Num)
+			corewarVAL.Instruction = Instruction{Comment: corewarDollar[1].Comment}
+		}
+	case 8:
+		corewarDollar = corewarS[corewarpt-1 : corewarpt+1]
+//line icws94.y:174
+		{
+			logger.Debug("redn' at comment", "EOL", corewarDollar[1].Num)
+			corewarVAL.Instruction = Instruction{}
+		}
+	case 9:
+		corewarDollar = corewarS[corewarpt-5 : corewarpt+1]
+//line icws94.y:177
+		{
+			logger.Debug("redn' at instruction", "LABEL_LIST", corewarDollar[1].LabelList, "OPERATION", corewarDollar[2].Operation, "MODE", corewarDollar[3].AddressingMode, "EXPR", corewarDollar[4].Expr, "COMMENT", corewarDollar[5].Instruction)
+			corewarVAL.Instruction = newInstruction(corewarDollar[1].LabelList, corewarDollar[1].Span, corewarDollar[2].Operation, corewarDollar[2].Span, newOperand(corewarDollar[3].AddressingMode, corewarDollar[3].Span, corewarDollar[4].Expr, corewarDollar[4].Span))
+		}
+	case 10:
+		corewarDollar = corewarS[corewarpt-4 : corewarpt+1]
+//line icws94.y:181
+		{
+			logger.Debug("redn' at instruction", "OPERATION", corewarDollar[1].Operation, "MODE", corewarDollar[2].AddressingMode, "EXPR", corewarDollar[3].Expr, "COMMENT", corewarDollar[4].Instruction)
+			corewarVAL.Instruction = newInstruction(nil, corewarDollar[1].Span, corewarDollar[1].Operation, corewarDollar[1].Span, newOperand(corewarDollar[2].AddressingMode, corewarDollar[2].Span, corewarDollar[3].Expr, corewarDollar[3].Span))
+		}
+	case 11:
+		corewarDollar = corewarS[corewarpt-8 : corewarpt+1]
+//line icws94.y:185
+		{
+			logger.Debug("redn' at instruction", "LABEL_LIST", corewarDollar[1].LabelList, "OPERATION", corewarDollar[2].Operation, "MODE", corewarDollar[3].AddressingMode, "EXPR", corewarDollar[4].Expr, "MODE", corewarDollar[6].AddressingMode, "EXPR", corewarDollar[7].Expr, "COMMENT", corewarDollar[8].Instruction)
+			corewarVAL.Instruction = newInstruction(corewarDollar[1].LabelList, corewarDollar[1].Span, corewarDollar[2].Operation, corewarDollar[2].Span, newOperand(corewarDollar[3].AddressingMode, corewarDollar[3].Span, corewarDollar[4].Expr, corewarDollar[4].Span), newOperand(corewarDollar[6].AddressingMode, corewarDollar[6].Span, corewarDollar[7].Expr, corewarDollar[7].Span))
+		}
+	case 12:
+		corewarDollar = corewarS[corewarpt-7 : corewarpt+1]
+//line icws94.y:189
+		{
+			logger.Debug("redn' at instruction", "OPERATION", corewarDollar[1].Operation, "MODE", corewarDollar[2].AddressingMode, "EXPR", corewarDollar[3].Expr, "MODE", corewarDollar[5].AddressingMode, "EXPR", corewarDollar[6].Expr, "COMMENT", corewarDollar[7].Instruction)
+			corewarVAL.Instruction = newInstruction(nil, corewarDollar[1].Span, corewarDollar[1].Operation, corewarDollar[1].Span, newOperand(corewarDollar[2].AddressingMode, corewarDollar[2].Span, corewarDollar[3].Expr, corewarDollar[3].Span), newOperand(corewarDollar[5].AddressingMode, corewarDollar[5].Span, corewarDollar[6].Expr, corewarDollar[6].Span))
+		}
+	case 13:
+		corewarDollar = corewarS[corewarpt-3 : corewarpt+1]
+//line icws94.y:194
+		{
+			logger.Debug("redn' at instruction", "LABEL_LIST", corewarDollar[1].LabelList, "OPERATION", corewarDollar[2].Operation, "COMMENT", corewarDollar[3].Instruction)
+			corewarVAL.Instruction = newInstruction(corewarDollar[1].LabelList, corewarDollar[1].Span, corewarDollar[2].Operation, corewarDollar[2].Span)
+		}
+	case 14:
+		corewarDollar = corewarS[corewarpt-2 : corewarpt+1]
+//line icws94.y:199
+		{
+			logger.Debug("redn' at instruction", "OPERATION", corewarDollar[1].Operation, "COMMENT", corewarDollar[2].Instruction)
+			corewarVAL.Instruction = newInstruction(nil, corewarDollar[1].Span, corewarDollar[1].Operation, corewarDollar[1].Span)
+		}
+	case 15:
+		corewarDollar = corewarS[corewarpt-1 : corewarpt+1]
+//line icws94.y:205
+		{
+			logger.Debug("redn' at label_list", "LABEL", corewarDollar[1].Label)
+			corewarVAL.LabelList = []Label{corewarDollar[1].Label}
+		}
+	case 16:
+		corewarDollar = corewarS[corewarpt-2 : corewarpt+1]
+//line icws94.y:206
+		{
+			logger.Debug("redn' at label_list", "LABEL", corewarDollar[1].Label, "LABEL_LIST", corewarDollar[2].LabelList)
+			corewarVAL.LabelList = append(corewarDollar[2].LabelList, corewarDollar[1].Label)
+		}
+	case 17:
+		corewarDollar = corewarS[corewarpt-3 : corewarpt+1]
+//line icws94.y:207
+		{
+			logger.Debug("redn' at label_list", "LABEL", corewarDollar[1].Label, "EOL", corewarDollar[2].Num, "LABEL_LIST", corewarDollar[3].LabelList)
+			corewarVAL.LabelList = append(corewarDollar[3].LabelList, corewarDollar[1].Label)
+		}
+	case 18:
+		corewarDollar = corewarS[corewarpt-1 : corewarpt+1]
+//line icws94.y:210
+		{
+			logger.Debug("redn' at operation", "OPCODE", corewarDollar[1].Opcode)
+			corewarVAL.Operation = Operation{corewarDollar[1].Opcode, OPCODE_MODIFIER_INVALID}
+		}
+	case 19:
+		corewarDollar = corewarS[corewarpt-2 : corewarpt+1]
+//line icws94.y:211
+		{
+			logger.Debug("redn' at operation", "OPCODE", corewarDollar[1].Opcode, "OPCODE_MODIFIER", corewarDollar[2].OpcodeModifier)
+			corewarVAL.Operation = Operation{corewarDollar[1].Opcode, corewarDollar[2].OpcodeModifier}
+			corewarVAL.Span = corewarDollar[1].Span.Join(corewarDollar[2].Span)
+		}
+	case 20:
+		corewarDollar = corewarS[corewarpt-1 : corewarpt+1]
+//line icws94.y:214
+		{
+			logger.Debug("redn' at mode", "ADDRESSING_MODE", corewarDollar[1].AddressingMode)
+			corewarVAL.AddressingMode = corewarDollar[1].AddressingMode
+		}
+	case 21:
+		corewarDollar = corewarS[corewarpt-0 : corewarpt+1]
+//line icws94.y:215
+		{
+			logger.Debug("redn' at mode", "ADDRESSING_MODE", "EMPTY")
+			corewarVAL.AddressingMode = ADDRESSING_MODE_INVALID
+			corewarVAL.Span = lexer.Span{}
+		}
+	case 22:
+		corewarDollar = corewarS[corewarpt-1 : corewarpt+1]
+//line icws94.y:222
+		{
+			logger.Debug("redn' at expr", "MUL_EXPR", corewarDollar[1].Expr)
+			corewarVAL.Expr = corewarDollar[1].Expr
+		}
+	case 23:
+		corewarDollar = corewarS[corewarpt-3 : corewarpt+1]
+//line icws94.y:223
+		{
+			logger.Debug("redn' at expr", "EXPR", corewarDollar[1].Expr, "MUL_EXPR", corewarDollar[3].Expr)
+			corewarVAL.Expr = newBinaryExpr(Plus, corewarDollar[1].Expr, corewarDollar[3].Expr)
+			corewarVAL.Span = corewarDollar[1].Span.Join(corewarDollar[3].Span)
+		}
+	case 24:
+		corewarDollar = corewarS[corewarpt-3 : corewarpt+1]
+//line icws94.y:224
+		{
+			logger.Debug("redn' at expr", "EXPR", corewarDollar[1].Expr, "MUL_EXPR", corewarDollar[3].Expr)
+			corewarVAL.Expr = newBinaryExpr(Minus, corewarDollar[1].Expr, corewarDollar[3].Expr)
+			corewarVAL.Span = corewarDollar[1].Span.Join(corewarDollar[3].Span)
+		}
+	case 25:
+		corewarDollar = corewarS[corewarpt-1 : corewarpt+1]
+//line icws94.y:227
+		{
+			logger.Debug("redn' at mul_expr", "UNARY_EXPR", corewarDollar[1].Expr)
+			corewarVAL.Expr = corewarDollar[1].Expr
+		}
+	case 26:
+		corewarDollar = corewarS[corewarpt-3 : corewarpt+1]
+//line icws94.y:228
+		{
+			logger.Debug("redn' at mul_expr", "MUL_EXPR", corewarDollar[1].Expr, "UNARY_EXPR", corewarDollar[3].Expr)
+			corewarVAL.Expr = newBinaryExpr(Star, corewarDollar[1].Expr, corewarDollar[3].Expr)
+			corewarVAL.Span = corewarDollar[1].Span.Join(corewarDollar[3].Span)
+		}
+	case 27:
+		corewarDollar = corewarS[corewarpt-3 : corewarpt+1]
+//line icws94.y:229
+		{
+			logger.Debug("redn' at mul_expr", "MUL_EXPR", corewarDollar[1].Expr, "UNARY_EXPR", corewarDollar[3].Expr)
+			corewarVAL.Expr = newBinaryExpr(Slash, corewarDollar[1].Expr, corewarDollar[3].Expr)
+			corewarVAL.Span = corewarDollar[1].Span.Join(corewarDollar[3].Span)
+		}
+	case 28:
+		corewarDollar = corewarS[corewarpt-3 : corewarpt+1]
+//line icws94.y:230
+		{
+			logger.Debug("redn' at mul_expr", "MUL_EXPR", corewarDollar[1].Expr, "UNARY_EXPR", corewarDollar[3].Expr)
+			corewarVAL.Expr = newBinaryExpr(Percent, corewarDollar[1].Expr, corewarDollar[3].Expr)
+			corewarVAL.Span = corewarDollar[1].Span.Join(corewarDollar[3].Span)
+		}
+	case 29:
+		corewarDollar = corewarS[corewarpt-1 : corewarpt+1]
+//line icws94.y:233
+		{
+			logger.Debug("redn' at unary_expr", "TERM", corewarDollar[1].Term)
+			corewarVAL.Expr = Expr{Term: corewarDollar[1].Term}
+		}
+	case 30:
+		corewarDollar = corewarS[corewarpt-2 : corewarpt+1]
+//line icws94.y:234
+		{
+			logger.Debug("redn' at unary_expr", "UNARY_EXPR", corewarDollar[2].Expr)
+			corewarVAL.Expr = newUnaryExpr(Plus, corewarDollar[2].Expr)
+			corewarVAL.Span = corewarDollar[1].Span.Join(corewarDollar[2].Span)
+		}
+	case 31:
+		corewarDollar = corewarS[corewarpt-2 : corewarpt+1]
+//line icws94.y:235
+		{
+			logger.Debug("redn' at unary_expr", "UNARY_EXPR", corewarDollar[2].Expr)
+			corewarVAL.Expr = newUnaryExpr(Minus, corewarDollar[2].Expr)
+			corewarVAL.Span = corewarDollar[1].Span.Join(corewarDollar[2].Span)
+		}
+	case 32:
+		corewarDollar = corewarS[corewarpt-1 : corewarpt+1]
+//line icws94.y:238
+		{
+			logger.Debug("redn' at term", "LABEL", corewarDollar[1].Label)
+			corewarVAL.Term = Term{Label: corewarDollar[1].Label, Immediate: 0, Span: corewarDollar[1].Span}
+		}
+	case 33:
+		corewarDollar = corewarS[corewarpt-1 : corewarpt+1]
+//line icws94.y:239
+		{
+			logger.Debug("redn' at term", "NUMBER", corewarDollar[1].Num)
+			corewarVAL.Term = Term{Label: "", Immediate: corewarDollar[1].Num, Span: corewarDollar[1].Span}
+		}
+	case 34:
+		corewarDollar = corewarS[corewarpt-3 : corewarpt+1]
+//line icws94.y:240
+		{
+			logger.Debug("redn' at term", "EXPR", corewarDollar[2].Expr)
+			corewarVAL.Span = corewarDollar[1].Span.Join(corewarDollar[3].Span)
+			corewarVAL.Term = newParenTerm(corewarDollar[2].Expr, corewarVAL.Span)
+		}
+	}
+	goto corewarstack /* stack new state and value */
+}
@@ -5,10 +5,79 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+
+	"github.com/pcolladosoto/corewarg/diag"
+	"github.com/pcolladosoto/corewarg/lexer"
 )
 
-var logger *slog.Logger
+var logger = slog.Default()
+
+func init() {
+	// Syntax errors tell what the parser expected instead.
+	corewarErrorVerbose = true
+}
+
+// Program is the AST of a whole warrior.
+type Program struct {
+	Name         string        `json:"name"`
+	Warrior      string        `json:"warrior,omitempty"` // as given by ;name
+	Author       string        `json:"author,omitempty"`  // as given by ;author
+	Instructions []Instruction `json:"instructions"`
+}
+
+// Parse parses the warrior read from r. The name is only used in error
+// reports. Each call works on its own lexer and AST, so it's safe to run
+// several of them concurrently. Parsing carries on past errors, so the
+// error returned on failure is a diag.List holding every problem found,
+// sorted by position, unless reading from r failed.
+func Parse(name string, r io.Reader) (*Program, error) {
+	l := lexer.LexReader(name, r)
+	prog, err := parse(name, l)
+	if rerr := l.Err(); rerr != nil {
+		return nil, fmt.Errorf("error reading %q: %w", name, rerr)
+	}
+	return prog, err
+}
+
+// ParseString is like Parse, but it takes the warrior as a string.
+func ParseString(name, input string) (*Program, error) {
+	return parse(name, lexer.Lex(name, input))
+}
+
+func parse(name string, l *lexer.Lexer) (*Program, error) {
+	pp := newPreprocessor(name, l)
+
+	x := &corewarLex{name: name, l: pp}
+	if rc := corewarParse(x); rc != 0 && x.diags.Errors() == 0 {
+		x.diags.Errorf(x.pos, diag.Syntax, "parsing failed")
+	}
+
+	diags := append(pp.diags, x.diags...)
+	if err := diags.Err(); err != nil {
+		diags.Sort()
+		return nil, err
+	}
+	return &Program{Name: name, Warrior: pp.warrior, Author: pp.author, Instructions: x.program}, nil
+}
+
+// newInstruction builds an instruction out of the spans of its first
+// symbol, be it a label or the operation, and of the operation itself.
+// Spans of operands are already part of them.
+func newInstruction(labels []Label, start lexer.Span, op Operation, opSpan lexer.Span, operands ...Operand) Instruction {
+	span := start.Join(opSpan)
+	for _, operand := range operands {
+		span = span.Join(operand.Span)
+	}
+	return Instruction{Labels: labels, Operation: op, Operands: operands, Line: opSpan.Start.Line, Span: span}
+}
+
+// newOperand builds an operand out of its mode and expression, along with
+// their spans. Operands without a mode begin with their expression.
+func newOperand(mode AddressingMode, modeSpan lexer.Span, e Expr, exprSpan lexer.Span) Operand {
+	return Operand{Mode: mode, Expr: e, Span: modeSpan.Join(exprSpan)}
+}
 
 func (o Operation) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
@@ -19,9 +88,10 @@ func (o Operation) MarshalJSON() ([]byte, error) {
 
 func (o Operand) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Mode string `json:"mode"`
-		Expr Term   `json:"expr"`
-	}{Mode: o.Mode.String(), Expr: o.Expr})
+		Mode string     `json:"mode"`
+		Expr Expr       `json:"expr"`
+		Span lexer.Span `json:"span"`
+	}{Mode: o.Mode.String(), Expr: o.Expr, Span: o.Span})
 }
 
 func (i Instruction) String() string {
@@ -44,7 +114,9 @@ func (i Instruction) String() string {
 		buff.WriteString(fmt.Sprintf(".%s", i.Operation.Modifier))
 	}
 
-	buff.WriteString(" ")
+	if len(i.Operands) > 0 {
+		buff.WriteString(" ")
+	}
 
 	// operands
 	for j, operand := range i.Operands {
@@ -52,11 +124,7 @@ func (i Instruction) String() string {
 			buff.WriteString(fmt.Sprintf("%s", operand.Mode))
 		}
 
-		if operand.Expr.Label != "" {
-			buff.WriteString(fmt.Sprintf("%s", operand.Expr.Label))
-		} else {
-			buff.WriteString(fmt.Sprintf("%d", operand.Expr.Immediate))
-		}
+		buff.WriteString(operand.Expr.String())
 
 		if j == 0 && len(i.Operands) == 2 {
 			buff.WriteString(", ")
@@ -2,14 +2,19 @@ package parser
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 
-	"github.com/pcolladosoto/corewarg/lexer"
+	"github.com/pcolladosoto/corewarg/diag"
 )
 
 func init() {
@@ -61,8 +66,8 @@ func printAST(ast []Instruction) {
 func TestParserSimple(t *testing.T) {
 	tests := []string{"ADD.A  #0, #target\n", "ADD.A  #0, #target\nADD.A  #0, #target\n"}
 	for i, test := range tests {
-		if rc := corewarParse(&corewarLex{l: lexer.Lex("parseTest", test)}); rc != 0 {
-			t.Errorf("test %d failed", i)
+		if _, err := ParseString("parseTest", test); err != nil {
+			t.Errorf("test %d failed: %v", i, err)
 		}
 	}
 }
@@ -70,7 +75,7 @@ func TestParserSimple(t *testing.T) {
 func TestParserError(t *testing.T) {
 	tests := []string{"5 WRONG 4\n", "5 WRONG 4"}
 	for i, test := range tests {
-		if rc := corewarParse(&corewarLex{l: lexer.Lex("parseTest", test)}); rc == 0 {
+		if _, err := ParseString("parseTest", test); err == nil {
 			t.Errorf("test %d passed and it shouldn't...", i)
 		}
 	}
@@ -79,40 +84,501 @@ func TestParserError(t *testing.T) {
 func TestParserSingleFieldInstruction(t *testing.T) {
 	tests := []string{"foo faa JMP.A    #start\n"}
 	for i, test := range tests {
-		if rc := corewarParse(&corewarLex{l: lexer.Lex("parseTest", test)}); rc != 0 {
-			t.Errorf("test %d failed", i)
+		prog, err := ParseString("parseTest", test)
+		if err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+			continue
 		}
+		marshalAST(prog.Instructions)
 	}
-	marshalAST(programAST)
 }
 
 func TestParserNoMode(t *testing.T) {
 	tests := []string{"JMP.A    start\n", "ADD.A  0, target\n"}
 	for i, test := range tests {
-		if rc := corewarParse(&corewarLex{l: lexer.Lex("parseTest", test)}); rc != 0 {
-			t.Errorf("test %d failed", i)
+		prog, err := ParseString("parseTest", test)
+		if err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+			continue
 		}
+		marshalAST(prog.Instructions)
 	}
-	marshalAST(programAST)
 }
 
 func TestParserFiles(t *testing.T) {
-	dataDir := "../lexer/testdata"
-	paths, err := os.ReadDir(dataDir)
+	paths, err := filepath.Glob("../lexer/testdata/*.rc")
+	if err != nil {
+		t.Fatalf("error listing files: %v", err)
+	}
+	corpus, err := filepath.Glob("../lexer/testdata/corpus/*.rc")
 	if err != nil {
-		t.Fatalf("error reading dir contents: %v", err)
+		t.Fatalf("error listing files: %v", err)
 	}
 
-	for i, file := range paths {
-		prog, err := os.ReadFile(fmt.Sprintf("%s/%s", dataDir, file.Name()))
+	for i, path := range append(paths, corpus...) {
+		f, err := os.Open(path)
 		if err != nil {
-			t.Errorf("error reading file %q: %v", file.Name(), err)
+			t.Errorf("error opening file %q: %v", path, err)
+			continue
+		}
+
+		prog, err := Parse(filepath.Base(path), f)
+		f.Close()
+		if err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+			continue
+		}
+		marshalAST(prog.Instructions)
+		printAST(prog.Instructions)
+	}
+}
+
+func TestParseConcurrent(t *testing.T) {
+	progs := []string{
+		"ADD.A  #0, #target\n",
+		"foo faa JMP.A    #start\n",
+		"JMP.A    start\nADD.A  0, target\nDAT.F #0, #0\n",
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			in := progs[i%len(progs)]
+			prog, err := Parse(fmt.Sprintf("warrior%d", i), strings.NewReader(in))
+			if err != nil {
+				t.Errorf("warrior %d: unexpected error: %v", i, err)
+				return
+			}
+			if want := strings.Count(in, "\n"); len(prog.Instructions) != want {
+				t.Errorf("warrior %d: got %d instructions, want %d", i, len(prog.Instructions), want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestParserExpressions(t *testing.T) {
+	labels := map[Label]int{"a": 7, "b": 3, "step": 4}
+	resolve := func(l Label) (int, error) {
+		v, ok := labels[l]
+		if !ok {
+			return 0, fmt.Errorf("undefined label %q", l)
+		}
+		return v, nil
+	}
+
+	tests := []struct {
+		in   string
+		str  []string
+		want []int
+	}{
+		{"MOV 0, step*2+1\n", []string{"0", "step*2+1"}, []int{0, 9}},
+		{"DAT #-(a-b)\n", []string{"-(a-b)"}, []int{-4}},
+		{"DAT 1+2*3, (1+2)*3\n", []string{"1+2*3", "(1+2)*3"}, []int{7, 9}},
+		{"DAT a-b-1, a/b*b\n", []string{"a-b-1", "a/b*b"}, []int{3, 6}},
+		{"DAT a%b, -a%b\n", []string{"a%b", "-a%b"}, []int{1, -1}},
+		{"DAT +5, --5\n", []string{"+5", "--5"}, []int{5, 5}},
+		{"DAT 0, -1\n", []string{"0", "-1"}, []int{0, -1}},
+		{"DAT -a*-b, 10/3\n", []string{"-a*-b", "10/3"}, []int{21, 3}},
+	}
+
+	for i, test := range tests {
+		prog, err := ParseString("parseTest", test.in)
+		if err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+			continue
+		}
+
+		operands := prog.Instructions[0].Operands
+		if len(operands) != len(test.want) {
+			t.Errorf("test %d: got %d operands, want %d", i, len(operands), len(test.want))
+			continue
+		}
+
+		for j, operand := range operands {
+			if got := operand.Expr.String(); got != test.str[j] {
+				t.Errorf("test %d, operand %d: got expression %q, want %q", i, j, got, test.str[j])
+			}
+			got, err := operand.Expr.Eval(resolve)
+			if err != nil {
+				t.Errorf("test %d, operand %d: error evaluating: %v", i, j, err)
+				continue
+			}
+			if got != test.want[j] {
+				t.Errorf("test %d, operand %d: got %d, want %d", i, j, got, test.want[j])
+			}
+		}
+	}
+}
+
+func TestParserExpressionErrors(t *testing.T) {
+	tests := []string{"DAT 1/0\n", "DAT 5%(2-2)\n", "DAT undefined\n"}
+	resolve := func(l Label) (int, error) {
+		return 0, fmt.Errorf("undefined label %q", l)
+	}
+	for i, test := range tests {
+		prog, err := ParseString("parseTest", test)
+		if err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+			continue
+		}
+		if _, err := prog.Instructions[0].Operands[0].Expr.Eval(resolve); err == nil {
+			t.Errorf("test %d: evaluation succeeded and it shouldn't...", i)
+		}
+	}
+
+	for i, test := range []string{"DAT (1+2\n", "DAT 1+\n", "DAT 1 2\n"} {
+		if _, err := ParseString("parseTest", test); err == nil {
+			t.Errorf("syntax test %d passed and it shouldn't...", i)
 		}
+	}
+}
 
-		if rc := corewarParse(&corewarLex{l: lexer.Lex("parseTest", string(prog))}); rc != 0 {
-			t.Errorf("test %d failed", i)
+func TestParserLines(t *testing.T) {
+	in := "; header\n\nfoo\nbar  JMP 0\n\n  ; trailing\nDAT #1, #2\n"
+	prog, err := ParseString("parseTest", in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []int{4, 7}
+	if len(prog.Instructions) != len(want) {
+		t.Fatalf("got %d instructions, want %d", len(prog.Instructions), len(want))
+	}
+	for i, ins := range prog.Instructions {
+		if ins.Line != want[i] {
+			t.Errorf("instruction %d: got line %d, want %d", i, ins.Line, want[i])
+		}
+	}
+}
+
+func TestParserLineEndings(t *testing.T) {
+	f, err := os.Open("../lexer/testdata/mixed.rc")
+	if err != nil {
+		t.Fatalf("error opening file: %v", err)
+	}
+	defer f.Close()
+
+	prog, err := Parse("mixed.rc", f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if prog.Warrior != "Mixed" || prog.Author != "Anonymous" {
+		t.Errorf("got name %q and author %q, want \"Mixed\" and \"Anonymous\"", prog.Warrior, prog.Author)
+	}
+
+	want := []struct {
+		ins  string
+		line int
+	}{{"bar, foo MOV.I foo, bar", 7}, {"JMP foo", 8}, {"DAT #0", 9}, {"END", 10}}
+	if len(prog.Instructions) != len(want) {
+		t.Fatalf("got %d instructions, want %d", len(prog.Instructions), len(want))
+	}
+	for i, ins := range prog.Instructions {
+		if ins.String() != want[i].ins || ins.Line != want[i].line {
+			t.Errorf("instruction %d: got %q on line %d, want %q on line %d", i, ins, ins.Line, want[i].ins, want[i].line)
+		}
+	}
+}
+
+func TestParserSpans(t *testing.T) {
+	in := "foo\nbar  JMP.B  @-(1 + x)  ; far\n     SPL    0, <foo\nEND\n"
+	prog, err := ParseString("parseTest", in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	span := func(ins, opnd int) string {
+		i := prog.Instructions[ins]
+		if opnd < 0 {
+			return i.Span.String()
+		}
+		return i.Operands[opnd].Span.String()
+	}
+	term := func(ins, opnd int) string {
+		return prog.Instructions[ins].Operands[opnd].Expr.Term.Span.String()
+	}
+
+	tests := []struct {
+		got, want string
+	}{
+		{span(0, -1), "parseTest:1:1-2:22"},
+		{span(0, 0), "parseTest:2:13-22"},
+		{span(1, -1), "parseTest:3:6-20"},
+		{span(1, 0), "parseTest:3:13-14"},
+		{span(1, 1), "parseTest:3:16-20"},
+		{span(2, -1), "parseTest:4:1-4"},
+		{term(1, 1), "parseTest:3:17-20"},
+		{prog.Instructions[0].Operands[0].Expr.X.Term.Span.String(), "parseTest:2:15-22"},
+	}
+	for i, test := range tests {
+		if test.got != test.want {
+			t.Errorf("test %d: got span %s, want %s", i, test.got, test.want)
+		}
+	}
+}
+
+func TestParserReadError(t *testing.T) {
+	errBoom := errors.New("boom")
+	_, err := Parse("parseTest", io.MultiReader(strings.NewReader("DAT 0\n"), iotest.ErrReader(errBoom)))
+	if !errors.Is(err, errBoom) {
+		t.Errorf("got error %v, want %v", err, errBoom)
+	}
+}
+
+func TestParserErrorPosition(t *testing.T) {
+	_, err := ParseString("parseTest", "DAT 0\nMOV 1, 2 3\n")
+	if err == nil || !strings.HasPrefix(err.Error(), "parseTest:2:10: ") {
+		t.Errorf("got error %v, want it at parseTest:2:10", err)
+	}
+}
+
+func TestParserExtendedModes(t *testing.T) {
+	tests := []string{
+		"MOV.I *1, {2",
+		"MOV }a, *b",
+		"ADD #2*3, *2*3",
+		"DJN.B loop, {ptr",
+	}
+
+	for i, test := range tests {
+		prog, err := ParseString("parseTest", test+"\n")
+		if err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+			continue
+		}
+		if got := prog.Instructions[0].String(); got != test {
+			t.Errorf("test %d: got %q, want %q", i, got, test)
+		}
+	}
+}
+
+func TestParserDirectives(t *testing.T) {
+	tests := []struct {
+		in              string
+		warrior, author string
+	}{
+		{"DAT 0\n", "", ""},
+		{";name Imp\n;author A. K. Dewdney\nMOV 0, 1\n", "Imp", "A. K. Dewdney"},
+		{";redcode-94\n;name\tImp  \n; author   Someone\nMOV 0, 1\n", "Imp", "Someone"},
+		{";name Imp\n;name Dwarf\nMOV 0, 1 ; name Nope\n", "Imp", ""},
+		{";nameless Imp\n;name\nMOV 0, 1\n", "", ""},
+		{"MOV 0, 1\nEND\n;name Imp\n", "", ""},
+	}
+
+	for i, test := range tests {
+		prog, err := ParseString("parseTest", test.in)
+		if err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+			continue
+		}
+		if prog.Warrior != test.warrior || prog.Author != test.author {
+			t.Errorf("test %d: got %q by %q, want %q by %q", i, prog.Warrior, prog.Author, test.warrior, test.author)
+		}
+	}
+}
+
+func TestParserLoadFile(t *testing.T) {
+	in := "       ORG      1\n" +
+		"       DAT.F   #0,     #0\n" +
+		"       ADD.AB  #4,     $-1\n" +
+		"       MOV.AB  #0,     @-2\n" +
+		"       JMP.A   $-2,    #0\n" +
+		"       END\n"
+	want := []string{"ORG 1", "DAT.F #0, #0", "ADD.AB #4, $-1", "MOV.AB #0, @-2", "JMP.A $-2, #0", "END"}
+
+	prog, err := ParseString("parseTest", in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prog.Instructions) != len(want) {
+		t.Fatalf("got %d instructions, want %d", len(prog.Instructions), len(want))
+	}
+	for i, ins := range prog.Instructions {
+		if got := ins.String(); got != want[i] {
+			t.Errorf("instruction %d: got %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestParserEQU(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"step EQU 4\nADD.AB #step, step\n", []string{"ADD.AB #4, 4"}},
+		{"a EQU 2\nb EQU a*3\nDAT #b, #b+1\n", []string{"DAT #(2*3), #(2*3)+1"}},
+		{"x EQU 1+2 ; a comment\nDAT x*2\n", []string{"DAT (1+2)*2"}},
+		{"DAT k\nk EQU 5\n", []string{"DAT 5"}},
+		{"foo\nbar EQU 3\nDAT foo, bar\n", []string{"DAT 3, 3"}},
+		{"ptr EQU target+1\ntarget DAT ptr\n", []string{"target DAT (target+1)"}},
+		{
+			"dec  EQU SUB.AB #1, cnt\n" +
+				"     EQU JMN loop, cnt\n" +
+				"; comments don't get in the way\n" +
+				"loop dec\n" +
+				"cnt  DAT 0, 5\n",
+			[]string{"loop SUB.AB #1, cnt", "JMN loop, cnt", "cnt DAT 0, 5"},
+		},
+		{"top body\nbody EQU DAT 1\n", []string{"top DAT 1"}},
+		{"body EQU DAT 1\ntop body\nx EQU 2\nDAT x\n", []string{"top DAT 1", "DAT 2"}},
+		{"x EQU 3\n", nil},
+		{"", nil},
+	}
+
+	for i, test := range tests {
+		prog, err := ParseString("parseTest", test.in)
+		if err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+			continue
+		}
+
+		if len(prog.Instructions) != len(test.want) {
+			t.Errorf("test %d: got %d instructions, want %d", i, len(prog.Instructions), len(test.want))
+			continue
+		}
+
+		for j, ins := range prog.Instructions {
+			if got := ins.String(); got != test.want[j] {
+				t.Errorf("test %d, instruction %d: got %q, want %q", i, j, got, test.want[j])
+			}
+		}
+	}
+}
+
+func TestParserEQUErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want error
+		msg  string
+	}{
+		{"a EQU b\nb EQU a\nDAT a\n", ErrEQUCycle, "a -> b -> a"},
+		{"a EQU a+1\n", ErrEQUCycle, "a -> a"},
+		{"x EQU 1\nb EQU c\nc EQU d\nd EQU b*x\n", ErrEQUCycle, "b -> c -> d -> b"},
+		{"a EQU 1\na EQU 2\n", ErrEQURedefined, "parseTest:2:"},
+		{"DAT 0\n  EQU 2\n", ErrEQUNoLabel, "parseTest:2:"},
+		{"  EQU 3\n", ErrEQUNoLabel, "parseTest:1:"},
+	}
+
+	for i, test := range tests {
+		_, err := ParseString("parseTest", test.in)
+		if err == nil {
+			t.Errorf("test %d passed and it shouldn't...", i)
+			continue
+		}
+		if !errors.Is(err, test.want) {
+			t.Errorf("test %d: got %v, want %v", i, err, test.want)
+		}
+		if !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("test %d: error %q doesn't mention %q", i, err, test.msg)
+		}
+		var diags diag.List
+		if errors.As(err, &diags) && len(diags) != 1 {
+			t.Errorf("test %d: got %d diagnostics, want 1:\n%v", i, len(diags), err)
+		}
+	}
+}
+
+// TestParserCase checks that keywords are recognised whatever their case
+// and that they always come out upper-cased. Labels keep theirs.
+func TestParserCase(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"loop mov.ab #1, @loop\n", []string{"loop MOV.AB #1, @loop"}},
+		{"Jmp.X a\na Dat 0\n", []string{"JMP.X a", "a DAT 0"}},
+		{"step equ 4\nadd #step, x\nx dat 0\n", []string{"ADD #4, x", "x DAT 0"}},
+		{"DAT 0\nend\nDAT 1\n", []string{"DAT 0", "END"}},
+		{"i SPL.i i\n", []string{"i SPL.I i"}},
+	}
+
+	for i, test := range tests {
+		prog, err := ParseString("parseTest", test.in)
+		if err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+			continue
+		}
+		if len(prog.Instructions) != len(test.want) {
+			t.Errorf("test %d: got %d instructions, want %d", i, len(prog.Instructions), len(test.want))
+			continue
+		}
+		for j, ins := range prog.Instructions {
+			if got := ins.String(); got != test.want[j] {
+				t.Errorf("test %d, instruction %d: got %q, want %q", i, j, got, test.want[j])
+			}
+		}
+	}
+
+	// keywords can't be labels, no matter their case
+	for i, in := range []string{"end DAT 0\n", "mov JMP mov\n", "DAT dat\n"} {
+		if _, err := ParseString("parseTest", in); err == nil {
+			t.Errorf("label test %d (%q) passed and it shouldn't...", i, in)
+		}
+	}
+}
+
+func TestParserEND(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"DAT 0\nEND\nDAT 1\n", 2},
+		{"DAT 0\nEND 0 ; we're done\nthis isn't redcode at all 5WRONG\n", 2},
+		{"start JMP 0\n  END start\nk EQU 1\n", 2},
+		{"DAT 0\nEND", 2},
+	}
+
+	for i, test := range tests {
+		prog, err := ParseString("parseTest", test.in)
+		if err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+			continue
+		}
+		if len(prog.Instructions) != test.want {
+			t.Errorf("test %d: got %d instructions, want %d", i, len(prog.Instructions), test.want)
+		}
+		if last := prog.Instructions[len(prog.Instructions)-1]; last.Operation.Opcode != END {
+			t.Errorf("test %d: got %q as the last instruction, want END", i, last)
+		}
+	}
+}
+
+func TestParserRecovery(t *testing.T) {
+	in := "" +
+		"start MOV 0, 1 2\n" + // extra number
+		"      ADD.AB #1\n" +
+		"      DAT 0 &\n" + // lexical error
+		"      SUB #1,\n" + // missing operand
+		"      SPL.Q 0\n" + // bad modifier
+		"      JMP start\n" +
+		"      JMZ , 1\n" + // missing operand
+		"      END\n" +
+		"      MOV 0 0 0\n" // past END
+	want := []struct {
+		pos  string
+		code diag.Code
+	}{
+		{"parseTest:1:16", diag.Syntax},
+		{"parseTest:3:13", diag.BadToken},
+		{"parseTest:4:14", diag.Syntax},
+		{"parseTest:5:11", diag.BadToken},
+		{"parseTest:7:11", diag.Syntax},
+	}
+
+	_, err := ParseString("parseTest", in)
+	var diags diag.List
+	if !errors.As(err, &diags) {
+		t.Fatalf("got error %v, want a diag.List", err)
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d:\n%v", len(diags), len(want), err)
+	}
+	for i, d := range diags {
+		if d.Pos.String() != want[i].pos || d.Code != want[i].code || d.Severity != diag.Error {
+			t.Errorf("diagnostic %d: got %s %s at %s, want %s at %s", i, d.Severity, d.Code, d.Pos, want[i].code, want[i].pos)
 		}
-		marshalAST(programAST)
-		printAST(programAST)
 	}
 }
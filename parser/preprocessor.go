@@ -0,0 +1,300 @@
+package parser
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/pcolladosoto/corewarg/diag"
+	"github.com/pcolladosoto/corewarg/lexer"
+)
+
+var (
+	ErrEQUCycle     = errors.New("EQU cycle")
+	ErrEQURedefined = errors.New("EQU redefined")
+	ErrEQUNoLabel   = errors.New("EQU without a label")
+)
+
+// equ is an EQU definition. Continuation lines (i.e. lines with an EQU but
+// no labels right after a definition) are appended to the body with an
+// EOL in between, so that a single label can stand for several lines.
+type equ struct {
+	pos  lexer.Pos
+	body []lexer.Item
+}
+
+// preprocessor sits between the lexer and the parser and takes care of EQU
+// text substitution: definitions are dropped from the item stream and every
+// reference to them is replaced by their body. As the standard allows
+// using an EQU before defining it, we need to see the whole input first.
+// It also makes sure nothing past END reaches the parser and picks up the
+// ;name and ;author comments along the way. Lines the lexer chokes on are
+// reported and dropped so that the parser can carry on with the rest.
+type preprocessor struct {
+	name     string
+	defs     map[string]*equ
+	expanded map[string][]lexer.Item
+	items    []lexer.Item
+	diags    diag.List
+
+	warrior, author string
+}
+
+func newPreprocessor(name string, l *lexer.Lexer) *preprocessor {
+	p := &preprocessor{name: name, defs: map[string]*equ{}, expanded: map[string][]lexer.Item{}}
+
+	lines, last := p.splitLines(l)
+	code := p.collect(lines)
+
+	// Expand every definition up front so that cycles are
+	// reported even if the offending labels are never used.
+	names := make([]string, 0, len(p.defs))
+	for name := range p.defs {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int { return p.defs[a].pos.Offset - p.defs[b].pos.Offset })
+	for _, name := range names {
+		p.expand(name, nil)
+	}
+
+	for _, line := range code {
+		for _, item := range line {
+			if _, ok := p.defs[item.Val]; !ok || item.Typ != lexer.ItemLabel {
+				p.items = append(p.items, item)
+				continue
+			}
+			for _, sub := range p.expanded[item.Val] {
+				// expanded items come from wherever the EQU is used
+				sub.Pos = item.Pos
+				p.items = append(p.items, sub)
+			}
+		}
+	}
+	p.items = append(p.items, last)
+
+	return p
+}
+
+// NextItem hands the next preprocessed item over to the parser.
+func (p *preprocessor) NextItem() lexer.Item {
+	item := p.items[0]
+	if len(p.items) > 1 {
+		p.items = p.items[1:]
+	}
+	return item
+}
+
+// splitLines drains the lexer and splits the items into lines, each of them
+// ending with its EOL. The final EOF is returned on its own. Just like the
+// standard mandates, we stop reading right after END: whatever follows is
+// none of our business. Lines with lexical errors are left out altogether.
+func (p *preprocessor) splitLines(l *lexer.Lexer) ([][]lexer.Item, lexer.Item) {
+	lines, line := [][]lexer.Item{}, []lexer.Item{}
+	broken := false
+	for {
+		item := l.NextItem()
+		switch item.Typ {
+		case lexer.ItemError:
+			p.diags.Errorf(item.Pos, diag.BadToken, "%s", item.Val)
+			broken = true
+		case lexer.ItemEOF:
+			if len(line) > 0 && !broken {
+				// the grammar wants every line to end with an EOL: think of a
+				// final END with no newline after it.
+				lines = append(lines, append(line, lexer.Item{Typ: lexer.ItemEOL, Pos: item.Pos}))
+			}
+			return lines, item
+		case lexer.ItemEOL:
+			if broken {
+				line, broken = []lexer.Item{}, false
+				continue
+			}
+			lines = append(lines, append(line, item))
+			if _, rest := splitLabels(line); len(rest) > 0 && isEND(rest[0]) {
+				return lines, lexer.Item{Typ: lexer.ItemEOF, Pos: item.Span().End}
+			}
+			line = []lexer.Item{}
+		default:
+			line = append(line, item)
+		}
+	}
+}
+
+// collect records the EQU definitions and returns the remaining lines.
+func (p *preprocessor) collect(lines [][]lexer.Item) [][]lexer.Item {
+	code := [][]lexer.Item{}
+
+	// lines holding nothing but labels are kept around in case they
+	// belong to an EQU. The standard allows spreading labels that way.
+	pending := [][]lexer.Item{}
+	var last *equ
+
+	// a line using an EQU, even one defined further down, is code: think
+	// of 'loop dec' before 'dec EQU ...'. Only definitions sharing their
+	// line with the EQU can be told apart up front.
+	later := map[string]bool{}
+	for _, line := range lines {
+		labels, rest := splitLabels(stripComment(line))
+		if len(rest) > 0 && isEQU(rest[0]) {
+			for _, label := range labels {
+				later[label.Val] = true
+			}
+		}
+	}
+	usesEQU := func(labels []lexer.Item) bool {
+		return slices.ContainsFunc(labels, func(label lexer.Item) bool {
+			_, ok := p.defs[label.Val]
+			return ok || later[label.Val]
+		})
+	}
+
+	for _, line := range lines {
+		labels, rest := splitLabels(stripComment(line))
+		switch {
+		case len(rest) > 0 && isEQU(rest[0]):
+			for _, l := range pending {
+				labels = append(labels, l[:len(l)-1]...) // drop the EOL
+			}
+			pending = nil
+
+			if len(labels) == 0 {
+				if last == nil {
+					p.diags.Errorf(rest[0].Pos, diag.EQUNoLabel, "%w", ErrEQUNoLabel)
+					continue
+				}
+				last.body = append(last.body, lexer.Item{Typ: lexer.ItemEOL, Val: "\n"})
+				last.body = append(last.body, rest[1:]...)
+				continue
+			}
+
+			last = &equ{pos: rest[0].Pos, body: rest[1:]}
+			for _, label := range labels {
+				if def, ok := p.defs[label.Val]; ok {
+					p.diags.Errorf(label.Pos, diag.EQURedefined, "%w: %q (first defined on line %d)", ErrEQURedefined, label.Val, def.pos.Line)
+					continue
+				}
+				p.defs[label.Val] = last
+			}
+
+		case len(labels) > 0 && len(rest) == 0 && !usesEQU(labels):
+			pending = append(pending, line)
+			last = nil
+
+		case len(labels) == 0 && len(rest) == 0:
+			// comments and blank lines don't break EQU continuations
+			p.directive(line)
+			code = append(code, line)
+
+		default:
+			code = append(code, slices.Concat(pending...))
+			code = append(code, line)
+			pending = nil
+			last = nil
+		}
+	}
+
+	return append(code, slices.Concat(pending...))
+}
+
+// directive records the warrior's name or author should line be a
+// comment giving them, as in ';name Dwarf'. The first one wins.
+func (p *preprocessor) directive(line []lexer.Item) {
+	if len(line) == 0 || line[0].Typ != lexer.ItemComment {
+		return
+	}
+
+	text := strings.TrimSpace(line[0].Val)
+	key := strings.IndexFunc(text, unicode.IsSpace)
+	if key == -1 {
+		return
+	}
+
+	value := strings.TrimSpace(text[key:])
+	switch key := text[:key]; {
+	case key == "name" && p.warrior == "":
+		p.warrior = value
+	case key == "author" && p.author == "":
+		p.author = value
+	}
+}
+
+// expand returns the body of the EQU called name with every reference to other
+// EQUs substituted. The stack holds the chain of EQUs being expanded and it's
+// used to detect cycles. The EQU closing a cycle is left as a plain label so
+// that parsing can go on without bogus errors.
+func (p *preprocessor) expand(name string, stack []string) []lexer.Item {
+	if body, ok := p.expanded[name]; ok {
+		return body
+	}
+
+	def := p.defs[name]
+	if i := slices.Index(stack, name); i != -1 {
+		cycle := append(slices.Clone(stack[i:]), name)
+		p.diags.Errorf(def.pos, diag.EQUCycle, "%w: %s", ErrEQUCycle, strings.Join(cycle, " -> "))
+		return []lexer.Item{{Typ: lexer.ItemLabel, Val: name, Pos: def.pos}}
+	}
+	stack = append(stack, name)
+
+	body := []lexer.Item{}
+	for _, item := range def.body {
+		if _, ok := p.defs[item.Val]; ok && item.Typ == lexer.ItemLabel {
+			body = append(body, p.expand(item.Val, stack)...)
+			continue
+		}
+		body = append(body, item)
+	}
+
+	// Parenthesise expressions so that they keep their meaning wherever
+	// they're substituted: think of 'x EQU 1+2' used as 'x*2'.
+	if len(body) > 1 && isExpression(body) {
+		body = slices.Concat(
+			[]lexer.Item{{Typ: lexer.ItemOperand, Val: "("}},
+			body,
+			[]lexer.Item{{Typ: lexer.ItemOperand, Val: ")"}},
+		)
+	}
+
+	p.expanded[name] = body
+	return body
+}
+
+// stripComment drops the trailing comment and EOL off a line.
+func stripComment(line []lexer.Item) []lexer.Item {
+	for i, item := range line {
+		if item.Typ == lexer.ItemComment || item.Typ == lexer.ItemEOL {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitLabels splits the leading labels off a line.
+func splitLabels(line []lexer.Item) ([]lexer.Item, []lexer.Item) {
+	for i, item := range line {
+		if item.Typ != lexer.ItemLabel {
+			return line[:i], line[i:]
+		}
+	}
+	return line, nil
+}
+
+func isEQU(item lexer.Item) bool {
+	return item.Typ == lexer.ItemOpcode && strings.EqualFold(item.Val, EQU.String())
+}
+
+func isEND(item lexer.Item) bool {
+	return item.Typ == lexer.ItemOpcode && strings.EqualFold(item.Val, END.String())
+}
+
+// isExpression reports whether items make up nothing but an expression.
+func isExpression(items []lexer.Item) bool {
+	for _, item := range items {
+		switch item.Typ {
+		case lexer.ItemLabel, lexer.ItemNumber, lexer.ItemOperand:
+		default:
+			return false
+		}
+	}
+	return true
+}